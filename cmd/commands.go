@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -39,3 +40,19 @@ func getCurrentApp(cliConnection plugin.CliConnection) (string, error) {
 
 	return "", fmt.Errorf("no apps found in current space")
 }
+
+// registryCredentials returns the registry credentials from the environment, defaulting to
+// the credentials of the local development registry
+func registryCredentials() (string, string) {
+	registryUsername := os.Getenv("REGISTRY_USERNAME")
+	if registryUsername == "" {
+		registryUsername = "user"
+	}
+
+	registryPassword := os.Getenv("REGISTRY_PASSWORD")
+	if registryPassword == "" {
+		registryPassword = "password"
+	}
+
+	return registryUsername, registryPassword
+}
@@ -0,0 +1,448 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const diffContextLines = 3
+
+// maxDiffSteps bounds the search for the middle snake. Ranges that need more edits,
+// such as regenerated lockfiles, are shown as removed and added as a whole.
+const maxDiffSteps = 2048
+
+type diffOp struct {
+	kind    byte // ' ' for unchanged, '-' for removed, '+' for added
+	text    string
+	oldLine int
+	newLine int
+}
+
+type fileChange struct {
+	path      string
+	status    string // "added", "deleted" or "modified"
+	binary    bool
+	ops       []diffOp
+	additions int
+	deletions int
+}
+
+// loadTree reads all regular files below root keyed by their slash separated relative path
+func loadTree(root string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relPath)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+
+	return files, nil
+}
+
+// diffTrees compares two file sets and returns the changed files sorted by path
+func diffTrees(oldFiles, newFiles map[string][]byte) []fileChange {
+	paths := make(map[string]bool)
+	for path := range oldFiles {
+		paths[path] = true
+	}
+	for path := range newFiles {
+		paths[path] = true
+	}
+
+	sortedPaths := make([]string, 0, len(paths))
+	for path := range paths {
+		sortedPaths = append(sortedPaths, path)
+	}
+	sort.Strings(sortedPaths)
+
+	var changes []fileChange
+	for _, path := range sortedPaths {
+		oldContent, inOld := oldFiles[path]
+		newContent, inNew := newFiles[path]
+
+		if inOld && inNew && bytes.Equal(oldContent, newContent) {
+			continue
+		}
+
+		change := fileChange{path: path, status: "modified"}
+		if !inOld {
+			change.status = "added"
+		} else if !inNew {
+			change.status = "deleted"
+		}
+
+		if isBinary(oldContent) || isBinary(newContent) {
+			change.binary = true
+		} else {
+			change.ops = diffLines(splitLines(oldContent), splitLines(newContent))
+			for _, op := range change.ops {
+				switch op.kind {
+				case '+':
+					change.additions++
+				case '-':
+					change.deletions++
+				}
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script between a and b using the linear space
+// variant of the Myers algorithm, so large rewritten files do not exhaust memory
+func diffLines(a, b []string) []diffOp {
+	d := &lineDiffer{a: a, b: b}
+	d.compare(0, len(a), 0, len(b))
+
+	oldLine, newLine := 0, 0
+	for i := range d.ops {
+		d.ops[i].oldLine = oldLine
+		d.ops[i].newLine = newLine
+		if d.ops[i].kind != '+' {
+			oldLine++
+		}
+		if d.ops[i].kind != '-' {
+			newLine++
+		}
+	}
+	return d.ops
+}
+
+// lineDiffer collects the edit script of a and b in order
+type lineDiffer struct {
+	a, b []string
+	ops  []diffOp
+}
+
+// compare appends the edit script of a[aLo:aHi] and b[bLo:bHi], splitting it at the
+// middle snake until one side is empty
+func (d *lineDiffer) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := aHi
+	for aHi > aLo && bHi > bLo && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.b[bLo:bHi] {
+			d.ops = append(d.ops, diffOp{kind: '+', text: line})
+		}
+	case bLo == bHi:
+		for _, line := range d.a[aLo:aHi] {
+			d.ops = append(d.ops, diffOp{kind: '-', text: line})
+		}
+	default:
+		x, y, ok := d.middleSnake(aLo, aHi, bLo, bHi)
+		if ok {
+			d.compare(aLo, x, bLo, y)
+			d.compare(x, aHi, y, bHi)
+		} else {
+			for _, line := range d.a[aLo:aHi] {
+				d.ops = append(d.ops, diffOp{kind: '-', text: line})
+			}
+			for _, line := range d.b[bLo:bHi] {
+				d.ops = append(d.ops, diffOp{kind: '+', text: line})
+			}
+		}
+	}
+
+	for _, line := range d.a[aHi:suffix] {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: line})
+	}
+}
+
+// middleSnake searches forward from the start and backward from the end of the ranges
+// at the same time and returns where the paths meet, a point on a shortest edit script.
+// ok is false if they do not meet within maxDiffSteps.
+func (d *lineDiffer) middleSnake(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// With an odd delta the paths meet while extending forward, otherwise backward
+	odd := delta%2 != 0
+	kForwardStart, kForwardEnd, kBackwardStart, kBackwardEnd := 0, 0, 0, 0
+
+	for step := 0; step < maxD && step < maxDiffSteps; step++ {
+		for k := -step + kForwardStart; k <= step-kForwardEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -step || (k != step && forward[i-1] < forward[i+1]) {
+				fx = forward[i+1]
+			} else {
+				fx = forward[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && d.a[aLo+fx] == d.b[bLo+fy] {
+				fx++
+				fy++
+			}
+			forward[i] = fx
+
+			switch {
+			case fx > n:
+				kForwardEnd += 2
+			case fy > m:
+				kForwardStart += 2
+			case odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && fx >= n-backward[j] {
+					return aLo + fx, bLo + fy, true
+				}
+			}
+		}
+
+		for k := -step + kBackwardStart; k <= step-kBackwardEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -step || (k != step && backward[i-1] < backward[i+1]) {
+				bx = backward[i+1]
+			} else {
+				bx = backward[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && d.a[aHi-bx-1] == d.b[bHi-by-1] {
+				bx++
+				by++
+			}
+			backward[i] = bx
+
+			switch {
+			case bx > n:
+				kBackwardEnd += 2
+			case by > m:
+				kBackwardStart += 2
+			case !odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					fx := forward[j]
+					fy := offset + fx - j
+					if fx >= n-bx {
+						return aLo + fx, bLo + fy, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// writeUnifiedDiff prints the changes in unified diff format, similar to 'git diff'
+func writeUnifiedDiff(w io.Writer, changes []fileChange) {
+	for _, change := range changes {
+		oldName := "a/" + change.path
+		newName := "b/" + change.path
+		if change.status == "added" {
+			oldName = "/dev/null"
+		}
+		if change.status == "deleted" {
+			newName = "/dev/null"
+		}
+
+		fmt.Fprintf(w, "diff --git a/%s b/%s\n", change.path, change.path)
+		switch change.status {
+		case "added":
+			fmt.Fprintf(w, "new file mode 100644\n")
+		case "deleted":
+			fmt.Fprintf(w, "deleted file mode 100644\n")
+		}
+
+		if change.binary {
+			fmt.Fprintf(w, "Binary files %s and %s differ\n", oldName, newName)
+			continue
+		}
+
+		fmt.Fprintf(w, "--- %s\n", oldName)
+		fmt.Fprintf(w, "+++ %s\n", newName)
+		writeHunks(w, change.ops)
+	}
+}
+
+func writeHunks(w io.Writer, ops []diffOp) {
+	i := 0
+	for i < len(ops) {
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			return
+		}
+
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContextLines {
+				end += diffContextLines
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = next
+		}
+
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		oldStart := ops[start].oldLine + 1
+		if oldCount == 0 {
+			oldStart--
+		}
+		newStart := ops[start].newLine + 1
+		if newCount == 0 {
+			newStart--
+		}
+
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(w, "%c%s\n", op.kind, op.text)
+		}
+
+		i = end
+	}
+}
+
+// writeDiffStat prints a per-file summary of insertions and deletions, similar to 'git diff --stat'
+func writeDiffStat(w io.Writer, changes []fileChange) {
+	const maxBarWidth = 40
+
+	pathWidth := 0
+	maxChanges := 0
+	totalAdditions, totalDeletions := 0, 0
+	for _, change := range changes {
+		if len(change.path) > pathWidth {
+			pathWidth = len(change.path)
+		}
+		if change.additions+change.deletions > maxChanges {
+			maxChanges = change.additions + change.deletions
+		}
+		totalAdditions += change.additions
+		totalDeletions += change.deletions
+	}
+
+	for _, change := range changes {
+		if change.binary {
+			fmt.Fprintf(w, " %-*s | Bin\n", pathWidth, change.path)
+			continue
+		}
+
+		additions, deletions := change.additions, change.deletions
+		if maxChanges > maxBarWidth {
+			additions = scaleBar(additions, maxChanges, maxBarWidth)
+			deletions = scaleBar(deletions, maxChanges, maxBarWidth)
+		}
+
+		fmt.Fprintf(w, " %-*s | %d %s%s\n", pathWidth, change.path, change.additions+change.deletions,
+			strings.Repeat("+", additions), strings.Repeat("-", deletions))
+	}
+
+	fmt.Fprintf(w, " %d %s changed, %d insertions(+), %d deletions(-)\n",
+		len(changes), pluralize(len(changes), "file", "files"), totalAdditions, totalDeletions)
+}
+
+func scaleBar(count, maxChanges, width int) int {
+	if count == 0 {
+		return 0
+	}
+	scaled := count * width / maxChanges
+	if scaled == 0 {
+		scaled = 1
+	}
+	return scaled
+}
+
+func pluralize(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// writeNameOnly prints only the paths of the changed files, similar to 'git diff --name-only'
+func writeNameOnly(w io.Writer, changes []fileChange) {
+	for _, change := range changes {
+		fmt.Fprintln(w, change.path)
+	}
+}
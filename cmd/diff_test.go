@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPromptDiffArgumentParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expected   PromptDiffOptions
+		shouldFail bool
+	}{
+		{
+			name:     "App and single hash",
+			args:     []string{"test", "a1b2c3d"},
+			expected: PromptDiffOptions{App: "test", Hash: "a1b2c3d"},
+		},
+		{
+			name:     "App and two hashes with --stat",
+			args:     []string{"test", "a1b2c3d", "--stat", "e4f5a6b"},
+			expected: PromptDiffOptions{App: "test", Hash: "a1b2c3d", OtherHash: "e4f5a6b", Stat: true},
+		},
+		{
+			name:     "Name only",
+			args:     []string{"--name-only", "test", "a1b2c3d"},
+			expected: PromptDiffOptions{App: "test", Hash: "a1b2c3d", NameOnly: true},
+		},
+		{
+			name:     "Variant group",
+			args:     []string{"test", "--variants", "3f9a2c1b7d4e", "--stat"},
+			expected: PromptDiffOptions{App: "test", Variants: "3f9a2c1b7d4e", Stat: true},
+		},
+		{
+			name:       "Variant group with hash",
+			args:       []string{"test", "a1b2c3d", "--variants", "3f9a2c1b7d4e"},
+			shouldFail: true,
+		},
+		{
+			name:       "Missing hash",
+			args:       []string{"test"},
+			shouldFail: true,
+		},
+		{
+			name:       "Conflicting output modes",
+			args:       []string{"test", "a1b2c3d", "--stat", "--name-only"},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptDiffArgs(tt.args)
+
+			if tt.shouldFail != failed {
+				t.Fatalf("Expected failed=%v, got %v", tt.shouldFail, failed)
+			}
+			if !tt.shouldFail && opts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	oldFiles := map[string][]byte{
+		"main.go":    []byte("package main\n\nfunc main() {\n\tprintln(\"hello world\")\n}\n"),
+		"removed.go": []byte("package main\n"),
+		"same.txt":   []byte("unchanged\n"),
+	}
+	newFiles := map[string][]byte{
+		"main.go":  []byte("package main\n\nfunc main() {\n\tprintln(\"foo bar\")\n}\n"),
+		"added.go": []byte("package main\n\nfunc helper() {}\n"),
+		"same.txt": []byte("unchanged\n"),
+	}
+
+	changes := diffTrees(oldFiles, newFiles)
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changed files, got %d", len(changes))
+	}
+
+	var buf bytes.Buffer
+	writeUnifiedDiff(&buf, changes)
+	output := buf.String()
+
+	expected := []string{
+		"--- /dev/null\n+++ b/added.go\n@@ -0,0 +1,3 @@\n+package main\n+\n+func helper() {}\n",
+		"--- a/main.go\n+++ b/main.go\n@@ -1,5 +1,5 @@\n package main\n \n func main() {\n-\tprintln(\"hello world\")\n+\tprintln(\"foo bar\")\n }\n",
+		"--- a/removed.go\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-package main\n",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("Diff output should contain:\n%s\ngot:\n%s", fragment, output)
+		}
+	}
+	if strings.Contains(output, "same.txt") {
+		t.Error("Diff output should not contain unchanged files")
+	}
+}
+
+func TestUnifiedDiffSplitsDistantHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1)
+		oldLines = append(oldLines, line)
+		newLines = append(newLines, line)
+	}
+	newLines[1] = "changed near the top"
+	newLines[18] = "changed near the bottom"
+
+	var buf bytes.Buffer
+	writeHunks(&buf, diffLines(oldLines, newLines))
+	output := buf.String()
+
+	if strings.Count(output, "@@ -") != 2 {
+		t.Errorf("Expected 2 hunks, got:\n%s", output)
+	}
+	if !strings.Contains(output, "@@ -1,5 +1,5 @@") || !strings.Contains(output, "@@ -16,5 +16,5 @@") {
+		t.Errorf("Unexpected hunk headers:\n%s", output)
+	}
+}
+
+func TestDiffLinesShortestEditScript(t *testing.T) {
+	// checkScript makes sure the ops turn a into b with the given number of edits
+	checkScript := func(t *testing.T, a, b []string, ops []diffOp, edits int) {
+		t.Helper()
+		var oldLines, newLines []string
+		changed := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				oldLines = append(oldLines, op.text)
+			}
+			if op.kind != '-' {
+				newLines = append(newLines, op.text)
+			}
+			if op.kind != ' ' {
+				changed++
+			}
+		}
+		if strings.Join(oldLines, "\n") != strings.Join(a, "\n") || strings.Join(newLines, "\n") != strings.Join(b, "\n") {
+			t.Fatal("Expected the edit script to turn the old lines into the new ones")
+		}
+		if changed != edits {
+			t.Errorf("Expected %d edits, got %d", edits, changed)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		edits int
+	}{
+		{name: "Identical", a: "a b c", b: "a b c", edits: 0},
+		{name: "Insertions", a: "a c", b: "a b c d", edits: 2},
+		{name: "Deletions", a: "a b c d", b: "b d", edits: 2},
+		{name: "Replaced", a: "a b c", b: "x y z", edits: 6},
+		{name: "Moved", a: "a b c d e f", b: "d e f a b c", edits: 6},
+		{name: "Interleaved", a: "a b c a b b a", b: "c b a b a c", edits: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			checkScript(t, a, b, diffLines(a, b), tt.edits)
+		})
+	}
+
+	t.Run("Large file with scattered changes", func(t *testing.T) {
+		var a, b []string
+		for i := 0; i < 50000; i++ {
+			a = append(a, fmt.Sprintf("line %d", i))
+			b = append(b, fmt.Sprintf("line %d", i))
+		}
+		for i := 0; i < 50000; i += 100 {
+			b[i] = fmt.Sprintf("changed line %d", i)
+		}
+		checkScript(t, a, b, diffLines(a, b), 2*500)
+	})
+
+	t.Run("Large rewritten file", func(t *testing.T) {
+		// Keeping a copy of the search state per edit, this took gigabytes of memory.
+		// Past maxDiffSteps the file is shown as removed and added as a whole, apart
+		// from the common first line.
+		var a, b []string
+		for i := 0; i < 20000; i++ {
+			a = append(a, fmt.Sprintf("old line %d", i))
+			b = append(b, fmt.Sprintf("new line %d", i))
+		}
+		for i := 0; i < 20000; i += 100 {
+			b[i] = a[i]
+		}
+		checkScript(t, a, b, diffLines(a, b), 2*(20000-1))
+	})
+}
+
+func TestDiffStat(t *testing.T) {
+	changes := diffTrees(
+		map[string][]byte{"a.txt": []byte("one\ntwo\n")},
+		map[string][]byte{"a.txt": []byte("one\nthree\nfour\n")},
+	)
+
+	var buf bytes.Buffer
+	writeDiffStat(&buf, changes)
+	output := buf.String()
+
+	if !strings.Contains(output, " a.txt | 3 ++-") {
+		t.Errorf("Unexpected stat line:\n%s", output)
+	}
+	if !strings.Contains(output, "1 file changed, 2 insertions(+), 1 deletions(-)") {
+		t.Errorf("Unexpected stat summary:\n%s", output)
+	}
+}
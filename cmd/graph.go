@@ -0,0 +1,56 @@
+package cmd
+
+type graphNode struct {
+	guid   string
+	parent string
+	label  string
+}
+
+// renderGraph lays out package revisions as a tree, with every revision below the
+// package it was created from. Nodes are expected newest first, as returned by the
+// API; the tree is rendered oldest first so each branch reads top to bottom.
+func renderGraph(nodes []graphNode) []string {
+	known := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		known[node.guid] = true
+	}
+
+	children := make(map[string][]graphNode)
+	var roots []graphNode
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		if node.parent != "" && node.parent != node.guid && known[node.parent] {
+			children[node.parent] = append(children[node.parent], node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var lines []string
+	visited := make(map[string]bool, len(nodes))
+
+	var walk func(node graphNode, linePrefix, childPrefix string)
+	walk = func(node graphNode, linePrefix, childPrefix string) {
+		if visited[node.guid] {
+			return
+		}
+		visited[node.guid] = true
+
+		lines = append(lines, linePrefix+node.label)
+
+		kids := children[node.guid]
+		for i, kid := range kids {
+			if i == len(kids)-1 {
+				walk(kid, childPrefix+"└─ ", childPrefix+"   ")
+			} else {
+				walk(kid, childPrefix+"├─ ", childPrefix+"│  ")
+			}
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, "", "")
+	}
+
+	return lines
+}
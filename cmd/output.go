@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validOutputFormat reports whether format is supported by writeStructuredOutput
+func validOutputFormat(format string) bool {
+	return format == "json" || format == "yaml"
+}
+
+// writeStructuredOutput writes v as indented JSON or YAML for use in scripts
+func writeStructuredOutput(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unsupported output format '%s'", format)
+	}
+}
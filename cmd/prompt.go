@@ -1,29 +1,146 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/agent"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
 )
 
+// PromptOptions holds the parsed arguments of the prompt command
+type PromptOptions struct {
+	App string
+	// Prompt is the prompt text, "-" reads it from stdin
+	Prompt string
+	// File is a file holding the prompt, or a YAML batch of prompts
+	File string
+	// Template is a saved prompt template rendered with Vars
+	Template    string
+	Vars        map[string]string
+	From        string
+	FromCurrent bool
+	Continue    string
+	// Validate is a command that must succeed on the changed source before a package is created
+	Validate         string
+	ValidateAttempts int
+	// Agent selects the coding agent backend, AgentCommand is the command run by the "command" agent
+	Agent        string
+	AgentCommand string
+	// Model and Provider select the language model, the agent's default is used when empty
+	Model    string
+	Provider string
+	// NoWait returns as soon as the prompter is started instead of following the run
+	NoWait bool
+	// Force breaks the lock of a run that is still holding the prompter
+	Force bool
+	// Queue queues the prompt if another run is active, to run on top of its result
+	Queue bool
+	// Task runs the prompt as a CF task on the prompter's droplet, without locking the prompter
+	Task bool
+	// Variants is the number of alternative packages to create for the prompt
+	Variants int
+	// VariantGroup and VariantIndex are set on the runs of a variant group
+	VariantGroup string
+	VariantIndex int
+}
+
 // ParsePromptArgs parses command line arguments and returns app name, prompt text, and whether parsing failed
 func ParsePromptArgs(args []string) (app string, prompt string, failed bool) {
-	if len(args) == 0 {
+	opts, failed := ParsePromptOptions(args)
+	if failed {
 		return "", "", true
 	}
+	return opts.App, opts.Prompt, false
+}
+
+// ParsePromptOptions parses command line arguments and returns the prompt options and whether parsing failed
+func ParsePromptOptions(args []string) (opts PromptOptions, failed bool) {
+	if len(args) == 0 {
+		return PromptOptions{}, true
+	}
 
 	var nonFlagArgs []string
 
 	// Parse arguments - support -a for app name and -p for prompt
 	for i := 0; i < len(args); i++ {
 		if (args[i] == "--app" || args[i] == "-a") && i+1 < len(args) {
-			app = args[i+1]
+			opts.App = args[i+1]
 			i++
 		} else if (args[i] == "--prompt" || args[i] == "-p") && i+1 < len(args) {
-			prompt = args[i+1]
+			opts.Prompt = args[i+1]
+			i++
+		} else if (args[i] == "--file" || args[i] == "-f") && i+1 < len(args) {
+			opts.File = args[i+1]
+			i++
+		} else if args[i] == "--template" && i+1 < len(args) {
+			opts.Template = args[i+1]
+			i++
+		} else if args[i] == "--var" && i+1 < len(args) {
+			name, value, ok := strings.Cut(args[i+1], "=")
+			if !ok || name == "" {
+				return PromptOptions{}, true
+			}
+			if opts.Vars == nil {
+				opts.Vars = make(map[string]string)
+			}
+			opts.Vars[name] = value
+			i++
+		} else if args[i] == "--from" && i+1 < len(args) {
+			opts.From = args[i+1]
+			i++
+		} else if args[i] == "--from-current" {
+			opts.FromCurrent = true
+		} else if args[i] == "--continue" && i+1 < len(args) {
+			opts.Continue = args[i+1]
+			i++
+		} else if args[i] == "--validate" && i+1 < len(args) {
+			opts.Validate = args[i+1]
+			i++
+		} else if args[i] == "--validate-attempts" && i+1 < len(args) {
+			attempts, err := strconv.Atoi(args[i+1])
+			if err != nil || attempts < 1 {
+				return PromptOptions{}, true
+			}
+			opts.ValidateAttempts = attempts
+			i++
+		} else if args[i] == "--agent" && i+1 < len(args) {
+			opts.Agent = args[i+1]
+			i++
+		} else if args[i] == "--agent-command" && i+1 < len(args) {
+			opts.AgentCommand = args[i+1]
+			i++
+		} else if args[i] == "--no-wait" {
+			opts.NoWait = true
+		} else if args[i] == "--force" {
+			opts.Force = true
+		} else if args[i] == "--queue" {
+			opts.Queue = true
+		} else if args[i] == "--task" {
+			opts.Task = true
+		} else if args[i] == "--variants" && i+1 < len(args) {
+			variants, err := strconv.Atoi(args[i+1])
+			if err != nil || variants < 1 {
+				return PromptOptions{}, true
+			}
+			opts.Variants = variants
+			i++
+		} else if args[i] == "--model" && i+1 < len(args) {
+			opts.Model = args[i+1]
+			i++
+		} else if args[i] == "--provider" && i+1 < len(args) {
+			opts.Provider = args[i+1]
 			i++
 		} else {
 			nonFlagArgs = append(nonFlagArgs, args[i])
@@ -31,29 +148,207 @@ func ParsePromptArgs(args []string) (app string, prompt string, failed bool) {
 	}
 
 	// If no -a flag was used, treat first non-flag argument as app name
-	if app == "" && len(nonFlagArgs) > 0 {
-		app = nonFlagArgs[0]
+	if opts.App == "" && len(nonFlagArgs) > 0 {
+		opts.App = nonFlagArgs[0]
 	}
 
-	if app == "" || prompt == "" {
-		return "", "", true
+	// The prompt is given as text, a file or a template, a batch file may name the apps itself
+	sources := 0
+	for _, set := range []bool{opts.Prompt != "", opts.File != "", opts.Template != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 || (opts.Vars != nil && opts.Template == "") {
+		return PromptOptions{}, true
+	}
+	if opts.App == "" && !isBatchFile(opts.File) {
+		return PromptOptions{}, true
+	}
+
+	return validatePromptOptions(opts)
+}
+
+// validatePromptOptions checks the combination of options of a run and returns whether it is invalid
+func validatePromptOptions(opts PromptOptions) (PromptOptions, bool) {
+	// Only one way of selecting the base package may be used
+	selectors := 0
+	for _, set := range []bool{opts.From != "", opts.FromCurrent, opts.Continue != ""} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return PromptOptions{}, true
+	}
+
+	// Queued prompts start from the package of the run before them
+	if opts.Queue && (selectors > 0 || opts.Force) {
+		return PromptOptions{}, true
+	}
+
+	// Task runs do not take the lock, so there is nothing to queue behind or break
+	if opts.Task && (opts.Queue || opts.Force) {
+		return PromptOptions{}, true
+	}
+
+	// Variants are followed one after another to pin them to the same base package
+	if opts.Variants > 1 && (opts.Queue || opts.NoWait) {
+		return PromptOptions{}, true
+	}
+
+	// A custom agent command implies the command agent
+	if opts.AgentCommand != "" && opts.Agent == "" {
+		opts.Agent = "command"
+	}
+	if opts.Agent != "" && !validAgent(opts.Agent) {
+		return PromptOptions{}, true
+	}
+	if (opts.Agent == "command") != (opts.AgentCommand != "") {
+		return PromptOptions{}, true
 	}
 
-	return app, prompt, false
+	// A provider only qualifies a model
+	if opts.Provider != "" && opts.Model == "" {
+		return PromptOptions{}, true
+	}
+
+	return opts, false
+}
+
+func validAgent(name string) bool {
+	for _, known := range agent.Names() {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// basePackage returns the base package selector passed to the prompter
+func (o PromptOptions) basePackage() string {
+	if o.FromCurrent {
+		return prompter.BasePackageCurrent
+	}
+	if o.Continue != "" {
+		return o.Continue
+	}
+	return o.From
 }
 
 func PromptCommand(cliConnection plugin.CliConnection, args []string) {
-	app, prompt, failed := ParsePromptArgs(args)
+	opts, failed := ParsePromptOptions(args)
 	if failed {
 		fmt.Println("Error: Invalid arguments")
-		fmt.Println("Usage: cf prompt APP_NAME -p 'prompt text'")
+		fmt.Println("Usage: cf prompt APP_NAME -p 'prompt text' [--from PACKAGE_HASH | --from-current | --continue PACKAGE_HASH]")
+		fmt.Printf("       [--agent %s] [--agent-command COMMAND] [--model MODEL [--provider PROVIDER]] [--no-wait] [--force | --queue | --task] [--variants N]\n", strings.Join(agent.Names(), "|"))
+		fmt.Println("   or: cf prompt APP_NAME -f PROMPT_FILE | -p -")
+		fmt.Println("   or: cf prompt [APP_NAME] -f BATCH.yml")
 		fmt.Println("   or: cf prompt -a APP_NAME -p 'prompt text'")
 		os.Exit(1)
 	}
 
-	fmt.Printf("Executing prompt on package for app: %s\n", app)
-	fmt.Printf("Prompt: %s\n", prompt)
+	if isBatchFile(opts.File) {
+		runPromptBatch(cliConnection, opts)
+		return
+	}
+
+	if err := loadPrompt(&opts, os.Stdin); err != nil {
+		fmt.Printf("Error reading prompt: %v\n", err)
+		os.Exit(1)
+	}
+	if err := renderTemplate(&opts); err != nil {
+		fmt.Printf("Error rendering template: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.App == "" {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt APP_NAME -f PROMPT_FILE")
+		os.Exit(1)
+	}
+	app := opts.App
+
+	if opts.Variants > 1 {
+		runVariants(cliConnection, opts)
+		return
+	}
+
+	printPromptHeader(opts)
+
+	target := newPromptTarget(cliConnection)
+
+	deployer, err := startRun(cliConnection, target, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		var locked *prompter.LockedError
+		if errors.As(err, &locked) {
+			// The status of a run on a shared prompter is shown for the app it works on
+			statusApp := app
+			if name := lockedAppName(target.client, locked); name != "" && name != app {
+				fmt.Printf("The run holding the lock works on app '%s'.\n", name)
+				statusApp = name
+			}
+			if opts.Task {
+				fmt.Printf("Use 'cf prompt-status %s' to check its progress, task runs can start once it has ended.\n", statusApp)
+			} else {
+				fmt.Printf("Use 'cf prompt-status %s' to check its progress, or pass --force to break the lock.\n", statusApp)
+			}
+		}
+		os.Exit(1)
+	}
+	if deployer == nil {
+		return
+	}
+
+	if opts.NoWait {
+		fmt.Println()
+		fmt.Printf("Prompt run %s started in the background.\n", deployer.RunID())
+		fmt.Printf("Use 'cf prompt-status %s' to check its progress or 'cf prompt-attach %s' to follow its logs.\n", app, app)
+		return
+	}
+
+	waitForRun(deployer, app)
+}
+
+// printPromptHeader prints the prompt and the options of a run before it is started
+func printPromptHeader(opts PromptOptions) {
+	fmt.Printf("Executing prompt on package for app: %s\n", opts.App)
+	if opts.Template != "" {
+		fmt.Printf("Template: %s\n", opts.Template)
+	}
+	fmt.Printf("Prompt: %s\n", opts.Prompt)
+	if opts.From != "" {
+		fmt.Printf("Base package: %s\n", opts.From)
+	} else if opts.FromCurrent {
+		fmt.Println("Base package: currently deployed package")
+	} else if opts.Continue != "" {
+		fmt.Printf("Continuing conversation from package: %s\n", opts.Continue)
+	}
+	if opts.Validate != "" {
+		fmt.Printf("Validation command: %s\n", opts.Validate)
+	}
+	if opts.Agent != "" {
+		fmt.Printf("Agent: %s\n", opts.Agent)
+	}
+	if opts.Provider != "" {
+		fmt.Printf("Model: %s/%s\n", opts.Provider, opts.Model)
+	} else if opts.Model != "" {
+		fmt.Printf("Model: %s\n", opts.Model)
+	}
+}
+
+// promptTarget is the CF API, org and space prompts are run in
+type promptTarget struct {
+	apiEndpoint string
+	token       string
+	spaceGUID   string
+	orgGUID     string
+	username    string
+	client      *cfclient.Client
+}
 
+// newPromptTarget reads the current target from the CLI and exits if it is incomplete
+func newPromptTarget(cliConnection plugin.CliConnection) promptTarget {
 	apiEndpoint, err := cliConnection.ApiEndpoint()
 	if err != nil {
 		fmt.Printf("Error getting API endpoint: %v\n", err)
@@ -78,63 +373,291 @@ func PromptCommand(cliConnection plugin.CliConnection, args []string) {
 		os.Exit(1)
 	}
 
+	username, err := cliConnection.Username()
+	if err != nil {
+		fmt.Printf("Error getting username: %v\n", err)
+		os.Exit(1)
+	}
+
 	client, err := cfclient.New(apiEndpoint, token)
 	if err != nil {
 		fmt.Printf("Error creating CF client: %v\n", err)
 		os.Exit(1)
 	}
 
-	appGUID, err := client.GetAppGUID(app, currentSpace.Guid)
+	return promptTarget{
+		apiEndpoint: apiEndpoint,
+		token:       token,
+		spaceGUID:   currentSpace.Guid,
+		orgGUID:     currentOrg.Guid,
+		username:    username,
+		client:      client,
+	}
+}
+
+// startRun starts the prompter of the app with a prompt. The returned deployer is nil
+// if the prompt was queued behind an active run instead.
+func startRun(cliConnection plugin.CliConnection, target promptTarget, opts PromptOptions) (*prompter.AppDeployer, error) {
+	client := target.client
+
+	appGUID, err := client.GetAppGUID(opts.App, target.spaceGUID)
 	if err != nil {
-		fmt.Printf("Error getting app GUID: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to get app GUID: %w", err)
 	}
 
-	prompterName := fmt.Sprintf("%s-prompter", app)
-	prompterGUID, err := client.GetAppGUID(prompterName, currentSpace.Guid)
-	if err != nil || prompterGUID == "" {
-		fmt.Printf("Error: Prompter app '%s' does not exist\n", prompterName)
-		fmt.Printf("Please run the following command first:\n")
-		fmt.Printf("  cf prompt-init %s\n", app)
-		os.Exit(1)
+	if baseHash := opts.basePackage(); baseHash != "" && baseHash != prompter.BasePackageCurrent {
+		if _, err := client.FindPackageByShortHash(appGUID, baseHash); err != nil {
+			return nil, fmt.Errorf("failed to find base package: %w", err)
+		}
 	}
 
-	registryUsername := os.Getenv("REGISTRY_USERNAME")
-	if registryUsername == "" {
-		registryUsername = "user"
+	prompterName, prompterGUID, err := findPrompter(client, opts.App, target.spaceGUID)
+	if err != nil {
+		return nil, err
 	}
 
-	registryPassword := os.Getenv("REGISTRY_PASSWORD")
-	if registryPassword == "" {
-		registryPassword = "password"
+	if opts.Queue {
+		queued, err := queuePrompt(client, prompterName, prompterGUID, appGUID, opts, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to queue prompt: %w", err)
+		}
+		if queued {
+			return nil, nil
+		}
+		fmt.Println("The prompter is idle, running the prompt now")
 	}
 
+	registryUsername, registryPassword := registryCredentials()
+
 	deployer := prompter.NewAppDeployer(cliConnection, prompterName)
 
 	if err := deployer.StartPrompter(
-		apiEndpoint,
-		token,
+		target.apiEndpoint,
+		target.token,
 		appGUID,
-		currentSpace.Guid,
-		currentOrg.Guid,
+		target.spaceGUID,
+		target.orgGUID,
 		registryUsername,
 		registryPassword,
-		prompt,
+		opts.Prompt,
+		prompter.RunOptions{
+			BasePackage:      opts.basePackage(),
+			Continue:         opts.Continue != "",
+			ValidateCommand:  opts.Validate,
+			ValidateAttempts: opts.ValidateAttempts,
+			Agent:            opts.Agent,
+			AgentCommand:     opts.AgentCommand,
+			Model:            opts.Model,
+			Provider:         opts.Provider,
+			VariantGroup:     opts.VariantGroup,
+			VariantIndex:     opts.VariantIndex,
+			Template:         opts.Template,
+			TemplateVars:     opts.Vars,
+			Owner:            target.username,
+			Force:            opts.Force,
+			Task:             opts.Task,
+		},
 	); err != nil {
-		fmt.Printf("Error starting prompter app: %v\n", err)
+		var locked *prompter.LockedError
+		if errors.As(err, &locked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to start prompter app: %w", err)
+	}
+
+	return deployer, nil
+}
+
+// executeRun starts a run and follows it until it ends, printing its summary. It
+// returns whether the run succeeded and the short hash of the package it created.
+func executeRun(cliConnection plugin.CliConnection, target promptTarget, opts PromptOptions) (succeeded bool, packageHash string) {
+	deployer, err := startRun(cliConnection, target, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false, ""
+	}
+
+	summary, err := followRun(deployer, opts.App)
+	if summary != nil {
+		fmt.Println()
+		writeRunSummary(os.Stdout, opts.App, summary)
+		if summary.PackageGUID != "" {
+			packageHash = cfclient.ShortHash(summary.PackageGUID)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false, packageHash
+	}
+	return true, packageHash
+}
+
+// waitForRun follows the logs of a started run until it ends, prints its summary and
+// exits with an error status if the run failed
+func waitForRun(deployer *prompter.AppDeployer, app string) {
+	summary, err := followRun(deployer, app)
+	if summary != nil {
+		fmt.Println()
+		writeRunSummary(os.Stdout, app, summary)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	defer func() {
+	fmt.Println("Prompt execution completed successfully")
+}
+
+// followRun follows the logs of a started run until it ends and cleans up after it.
+// Interrupting only detaches from the run, which continues in the background.
+func followRun(deployer *prompter.AppDeployer, app string) (*events.Summary, error) {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	go func() {
+		<-interrupted
+		fmt.Println()
+		fmt.Printf("Detached from prompt run %s, it continues in the background.\n", deployer.RunID())
+		fmt.Printf("Use 'cf prompt-status %s' to check its progress or 'cf prompt-attach %s' to follow its logs.\n", app, app)
+		os.Exit(130)
+	}()
+
+	summary, err := deployer.MonitorLogs(os.Stdout)
+	signal.Stop(interrupted)
+
+	// The prompter keeps running and holds the lock while it works through the queue.
+	// Tasks end by themselves and leave the prompter app alone.
+	processingQueue := err == nil && deployer.ProcessingQueue()
+	if !processingQueue && !deployer.IsTask() {
 		if err := deployer.StopPrompter(); err != nil {
 			// Silently ignore stop errors since the main task is complete
 		}
-	}()
+	}
+	if err := deployer.RemoveRunCredentials(); err != nil {
+		fmt.Printf("Warning: failed to remove run credentials: %v\n", err)
+	}
+	if !processingQueue {
+		if err := deployer.ReleaseLock(); err != nil {
+			fmt.Printf("Warning: failed to release prompter lock: %v\n", err)
+		}
+	} else {
+		fmt.Printf("The prompter continues with the queued prompts, use 'cf prompt-queue %s' to see them.\n", app)
+	}
 
-	if err := deployer.MonitorLogs(os.Stdout); err != nil {
-		fmt.Printf("Error monitoring logs: %v\n", err)
-		os.Exit(1)
+	return summary, err
+}
+
+// lockedAppName returns the name of the app the run holding the prompter lock works on,
+// or an empty string if it is unknown
+func lockedAppName(client *cfclient.Client, locked *prompter.LockedError) string {
+	if locked.AppGUID == "" {
+		return ""
+	}
+	app, err := client.GetApp(locked.AppGUID)
+	if err != nil {
+		return ""
+	}
+	return app.Name
+}
+
+// queuePrompt adds the prompt to the queue of the prompter if another run is active
+// and returns whether it did. An idle prompter runs the prompt right away instead.
+// The credentials of the user are stored for the queued run in its own run credentials
+// service, the prompter runs each queued prompt as the user who queued it.
+func queuePrompt(client *cfclient.Client, prompterName, prompterGUID, appGUID string, opts PromptOptions, target promptTarget) (queued bool, err error) {
+	annotations, err := client.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to read prompter state: %w", err)
 	}
 
-	fmt.Println("Prompt execution completed successfully")
+	lock, blocking := runstate.Blocking(annotations, time.Now())
+	if !blocking {
+		return false, nil
+	}
+
+	id, err := runstate.NewRunID()
+	if err != nil {
+		return false, err
+	}
+	entry := runstate.QueueEntry{
+		ID:                 id,
+		AppGUID:            appGUID,
+		Prompt:             opts.Prompt,
+		Validate:           opts.Validate,
+		ValidateAttempts:   opts.ValidateAttempts,
+		Agent:              opts.Agent,
+		AgentCommand:       opts.AgentCommand,
+		Model:              opts.Model,
+		Provider:           opts.Provider,
+		Template:           opts.Template,
+		SubmittedBy:        target.username,
+		SubmittedAt:        time.Now().UTC(),
+		CredentialsService: prompter.RunCredentialsName(prompterName, id),
+	}
+	if len(opts.Vars) > 0 {
+		vars, _ := json.Marshal(opts.Vars)
+		entry.TemplateVars = string(vars)
+	}
+	entryAnnotations, err := entry.Annotations()
+	if err != nil {
+		return false, err
+	}
+
+	token := target.token
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = token[7:]
+	}
+	registryUsername, registryPassword := registryCredentials()
+	if err := client.CreateRunCredentials(entry.CredentialsService, target.spaceGUID, prompterGUID, map[string]string{
+		"run_id":            id,
+		"CF_ACCESS_TOKEN":   token,
+		"REGISTRY_USERNAME": registryUsername,
+		"REGISTRY_PASSWORD": registryPassword,
+	}); err != nil {
+		client.DeleteRunCredentials(entry.CredentialsService, target.spaceGUID)
+		return false, fmt.Errorf("failed to create run credentials: %w", err)
+	}
+
+	if err := client.UpdateAppAnnotations(prompterGUID, entryAnnotations); err != nil {
+		client.DeleteRunCredentials(entry.CredentialsService, target.spaceGUID)
+		return false, fmt.Errorf("failed to add prompt to the queue: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("Prompt queued as %s at position %d, it runs after run %s of %s.\n", id, len(runstate.Queue(annotations))+1, lock.RunID, lock.Owner)
+	fmt.Printf("Use 'cf prompt-queue %s' to see the queue or 'cf prompt-status %s %s' once it has started.\n", opts.App, opts.App, id)
+	return true, nil
+}
+
+// writeRunSummary prints the result of a prompter run
+func writeRunSummary(w io.Writer, app string, summary *events.Summary) {
+	if summary.Succeeded() {
+		fmt.Fprintln(w, "Prompt run succeeded")
+	} else {
+		fmt.Fprintln(w, "Prompt run failed")
+	}
+
+	if summary.PackageGUID != "" {
+		fmt.Fprintf(w, "%-12s %s\n", "package:", cfclient.ShortHash(summary.PackageGUID))
+	}
+	if summary.Agent != "" {
+		fmt.Fprintf(w, "%-12s %s\n", "agent:", summary.Agent)
+	}
+	if summary.Validated {
+		result := "failed"
+		if summary.ValidationPassed {
+			result = "passed"
+		}
+		if summary.ValidationAttempts > 0 {
+			result = fmt.Sprintf("%s after %d fix-up %s", result, summary.ValidationAttempts, pluralize(summary.ValidationAttempts, "attempt", "attempts"))
+		}
+		fmt.Fprintf(w, "%-12s %s\n", "validation:", result)
+	}
+	if !summary.Succeeded() && summary.Phase != "" {
+		fmt.Fprintf(w, "%-12s %s\n", "failed in:", summary.Phase)
+	}
+
+	if summary.Succeeded() && summary.PackageGUID != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Run 'cf prompt-push %s %s' to deploy the new package.\n", app, cfclient.ShortHash(summary.PackageGUID))
+	}
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+func PromptAttachCommand(cliConnection plugin.CliConnection, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-attach <APP_NAME>")
+		os.Exit(1)
+	}
+
+	appName := args[0]
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	appGUID, err := client.GetAppGUID(appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", appName, err)
+		os.Exit(1)
+	}
+
+	prompterName, prompterGUID, err := findPrompter(client, appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	state, interrupted, err := loadRunState(client, prompterGUID, appGUID)
+	if err != nil {
+		fmt.Printf("Error reading run state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if state == nil {
+		fmt.Printf("No prompt runs recorded for app '%s'\n", appName)
+		os.Exit(1)
+	}
+
+	// Nothing to follow anymore, report how the run ended
+	if state.Finished() || interrupted {
+		writeRunState(os.Stdout, appName, *state, interrupted, time.Now())
+		if state.Status != runstate.StatusSucceeded {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("Attaching to prompt run %s of app %s...\n\n", state.RunID, appName)
+
+	deployer := prompter.NewAppDeployer(cliConnection, prompterName)
+	if err := deployer.Attach(apiEndpoint, token, currentSpace.Guid, *state); err != nil {
+		fmt.Printf("Error attaching to prompt run: %v\n", err)
+		os.Exit(1)
+	}
+
+	waitForRun(deployer, appName)
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+// PromptBatch is a series of prompts read from a YAML file
+type PromptBatch struct {
+	// App is the app of entries that do not name one
+	App     string             `yaml:"app"`
+	Prompts []PromptBatchEntry `yaml:"prompts"`
+}
+
+// PromptBatchEntry is a single prompt of a batch. One of Prompt, File or Template is
+// set, File is relative to the batch file. From is a package hash or "current".
+type PromptBatchEntry struct {
+	App      string            `yaml:"app"`
+	Prompt   string            `yaml:"prompt"`
+	File     string            `yaml:"file"`
+	Template string            `yaml:"template"`
+	Vars     map[string]string `yaml:"vars"`
+	From     string            `yaml:"from"`
+	Agent    string            `yaml:"agent"`
+	Model    string            `yaml:"model"`
+	Provider string            `yaml:"provider"`
+	Validate string            `yaml:"validate"`
+}
+
+// isBatchFile returns whether a prompt file holds a YAML batch instead of a single prompt
+func isBatchFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// loadPrompt reads the prompt from the prompt file or, for "-p -", from stdin
+func loadPrompt(opts *PromptOptions, stdin io.Reader) error {
+	var content []byte
+	var err error
+	switch {
+	case opts.File != "":
+		content, err = os.ReadFile(opts.File)
+	case opts.Prompt == "-":
+		content, err = io.ReadAll(stdin)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	opts.Prompt = strings.TrimSpace(string(content))
+	if opts.Prompt == "" {
+		return fmt.Errorf("the prompt is empty")
+	}
+	return nil
+}
+
+// ParsePromptBatch parses a batch file and returns the options of its runs in order.
+// Prompt files are read relative to dir, options given on the command line apply to
+// entries that do not set them. Templates are left to be rendered before the run.
+func ParsePromptBatch(data []byte, dir string, defaults PromptOptions) ([]PromptOptions, error) {
+	var batch PromptBatch
+	if err := yaml.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file: %w", err)
+	}
+	if len(batch.Prompts) == 0 {
+		return nil, fmt.Errorf("the batch file lists no prompts")
+	}
+
+	runs := make([]PromptOptions, 0, len(batch.Prompts))
+	for i, entry := range batch.Prompts {
+		opts := defaults
+		opts.File = ""
+		opts.Prompt = entry.Prompt
+		opts.Template, opts.Vars = entry.Template, entry.Vars
+
+		sources := 0
+		for _, set := range []bool{entry.Prompt != "", entry.File != "", entry.Template != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return nil, fmt.Errorf("prompt %d: only one of prompt, file and template can be set", i+1)
+		}
+		if entry.Vars != nil && entry.Template == "" {
+			return nil, fmt.Errorf("prompt %d: vars are only used with a template", i+1)
+		}
+
+		if entry.File != "" {
+			path := entry.File
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			opts.File = path
+			if err := loadPrompt(&opts, nil); err != nil {
+				return nil, fmt.Errorf("prompt %d: failed to read %s: %w", i+1, entry.File, err)
+			}
+			opts.File = ""
+		}
+		opts.Prompt = strings.TrimSpace(opts.Prompt)
+		if opts.Prompt == "" && opts.Template == "" {
+			return nil, fmt.Errorf("prompt %d: no prompt given", i+1)
+		}
+
+		// The app given on the command line takes precedence over the default of the batch
+		if entry.App != "" {
+			opts.App = entry.App
+		} else if opts.App == "" {
+			opts.App = batch.App
+		}
+		if opts.App == "" {
+			return nil, fmt.Errorf("prompt %d: no app given", i+1)
+		}
+
+		if entry.From == "current" {
+			opts.From, opts.FromCurrent, opts.Continue = "", true, ""
+		} else if entry.From != "" {
+			opts.From, opts.FromCurrent, opts.Continue = entry.From, false, ""
+		}
+		if entry.Agent != "" && entry.Agent != opts.Agent {
+			opts.Agent, opts.AgentCommand = entry.Agent, ""
+		}
+		if entry.Model != "" || entry.Provider != "" {
+			opts.Model, opts.Provider = entry.Model, entry.Provider
+		}
+		if entry.Validate != "" {
+			opts.Validate = entry.Validate
+		}
+
+		opts, invalid := validatePromptOptions(opts)
+		if invalid {
+			return nil, fmt.Errorf("prompt %d: invalid combination of options", i+1)
+		}
+		runs = append(runs, opts)
+	}
+
+	return runs, nil
+}
+
+// runPromptBatch runs the prompts of a batch file one after another and prints a
+// combined summary. The series stops at the first failed run, since later prompts
+// usually build on the packages of earlier ones.
+func runPromptBatch(cliConnection plugin.CliConnection, opts PromptOptions) {
+	if opts.NoWait || opts.Queue || opts.Variants > 1 {
+		fmt.Println("Error: --no-wait, --queue and --variants cannot be used with a batch file")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(opts.File)
+	if err != nil {
+		fmt.Printf("Error reading batch file: %v\n", err)
+		os.Exit(1)
+	}
+
+	runs, err := ParsePromptBatch(data, filepath.Dir(opts.File), opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for i := range runs {
+		if err := renderTemplate(&runs[i]); err != nil {
+			fmt.Printf("Error: prompt %d: %v\n", i+1, err)
+			os.Exit(1)
+		}
+	}
+
+	target := newPromptTarget(cliConnection)
+
+	table := newSimpleTable([]string{"#", "app", "status", "package", "prompt"})
+	failed := false
+	for i, run := range runs {
+		status, packageHash := "skipped", ""
+		if !failed {
+			fmt.Printf("\n[%d/%d] ", i+1, len(runs))
+			printPromptHeader(run)
+
+			var succeeded bool
+			succeeded, packageHash = executeRun(cliConnection, target, run)
+			status, failed = "succeeded", !succeeded
+			if failed {
+				status = "failed"
+			}
+		}
+
+		prompt := run.Prompt
+		if len(prompt) > 50 {
+			prompt = prompt[:47] + "..."
+		}
+		table.addRow(fmt.Sprintf("%d", i+1), run.App, status, packageHash, prompt)
+	}
+
+	fmt.Println()
+	fmt.Println("Batch summary:")
+	table.print()
+
+	if failed {
+		os.Exit(1)
+	}
+}
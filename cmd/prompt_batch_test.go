@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrompt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prompt.md")
+	if err := os.WriteFile(path, []byte("# Task\n\nAdd a /health endpoint\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := PromptOptions{App: "test", File: path}
+	if err := loadPrompt(&opts, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Prompt != "# Task\n\nAdd a /health endpoint" {
+		t.Errorf("Unexpected prompt from file: %q", opts.Prompt)
+	}
+
+	opts = PromptOptions{App: "test", Prompt: "-"}
+	if err := loadPrompt(&opts, strings.NewReader("add logging\n")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Prompt != "add logging" {
+		t.Errorf("Unexpected prompt from stdin: %q", opts.Prompt)
+	}
+
+	opts = PromptOptions{App: "test", Prompt: "-"}
+	if err := loadPrompt(&opts, strings.NewReader("  \n")); err == nil {
+		t.Error("Expected an error for an empty prompt")
+	}
+}
+
+func TestParsePromptBatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "logging.md"), []byte("add request logging\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := `
+app: my-app
+prompts:
+  - prompt: rename /status to /health
+    validate: go test ./...
+  - file: logging.md
+    model: claude-sonnet-4-5
+    provider: anthropic
+  - app: other-app
+    prompt: bump the client timeout
+    from: current
+  - template: upgrade
+    vars:
+      name: gin
+`
+	runs, err := ParsePromptBatch([]byte(batch), dir, PromptOptions{Agent: "aider"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []PromptOptions{
+		{App: "my-app", Prompt: "rename /status to /health", Validate: "go test ./...", Agent: "aider"},
+		{App: "my-app", Prompt: "add request logging", Agent: "aider", Model: "claude-sonnet-4-5", Provider: "anthropic"},
+		{App: "other-app", Prompt: "bump the client timeout", FromCurrent: true, Agent: "aider"},
+		{App: "my-app", Template: "upgrade", Vars: map[string]string{"name": "gin"}, Agent: "aider"},
+	}
+	if len(runs) != len(expected) {
+		t.Fatalf("Expected %d runs, got %d", len(expected), len(runs))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(runs[i], expected[i]) {
+			t.Errorf("Run %d: expected %+v, got %+v", i+1, expected[i], runs[i])
+		}
+	}
+
+	// The app on the command line replaces the default of the batch
+	runs, err = ParsePromptBatch([]byte(batch), dir, PromptOptions{App: "staging-app"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runs[0].App != "staging-app" || runs[2].App != "other-app" {
+		t.Errorf("Unexpected apps: %s, %s", runs[0].App, runs[2].App)
+	}
+
+	invalid := map[string]string{
+		"no prompts":            "app: my-app\nprompts: []\n",
+		"no app":                "prompts:\n  - prompt: add logging\n",
+		"prompt and file":       "app: my-app\nprompts:\n  - prompt: add logging\n    file: logging.md\n",
+		"missing file":          "app: my-app\nprompts:\n  - file: missing.md\n",
+		"provider only":         "app: my-app\nprompts:\n  - prompt: add logging\n    provider: anthropic\n",
+		"unknown agent":         "app: my-app\nprompts:\n  - prompt: add logging\n    agent: unknown\n",
+		"malformed yaml":        "app: [my-app\n",
+		"prompt missing text":   "app: my-app\nprompts:\n  - validate: go test ./...\n",
+		"vars without template": "app: my-app\nprompts:\n  - prompt: add logging\n    vars:\n      name: gin\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePromptBatch([]byte(content), dir, PromptOptions{}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
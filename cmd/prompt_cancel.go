@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// cancelGracePeriod is how long the prompter gets to notice the cancellation and clean up
+// after itself before its app is stopped
+const cancelGracePeriod = 30 * time.Second
+
+func PromptCancelCommand(cliConnection plugin.CliConnection, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-cancel <APP_NAME>")
+		os.Exit(1)
+	}
+
+	appName := args[0]
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentOrg, err := cliConnection.GetCurrentOrg()
+	if err != nil {
+		fmt.Printf("Error getting current org: %v\n", err)
+		os.Exit(1)
+	}
+
+	username, err := cliConnection.Username()
+	if err != nil {
+		fmt.Printf("Error getting username: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	appGUID, err := client.GetAppGUID(appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", appName, err)
+		os.Exit(1)
+	}
+
+	prompterName, prompterGUID, err := findPrompter(client, appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	state, interrupted, err := loadRunState(client, prompterGUID, appGUID)
+	if err != nil {
+		fmt.Printf("Error reading run state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if state == nil || state.Finished() {
+		fmt.Printf("No prompt run in progress for app '%s'\n", appName)
+		return
+	}
+
+	fmt.Printf("Cancelling prompt run %s of app %s in org %s / space %s as %s...\n\n", state.RunID, appName, currentOrg.Name, currentSpace.Name, username)
+
+	if !interrupted {
+		if err := client.UpdateAppAnnotations(prompterGUID, map[string]string{runstate.KeyCancel: state.RunID}); err != nil {
+			fmt.Printf("Error requesting cancellation: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Waiting for the prompter to stop...")
+		task, isTask, err := client.FindTask(prompterGUID, prompter.TaskName(state.RunID))
+		if err != nil {
+			fmt.Printf("Error looking up prompter task: %v\n", err)
+			os.Exit(1)
+		}
+		if isTask {
+			if !waitForTaskFinished(client, task.GUID, cancelGracePeriod) {
+				fmt.Printf("Prompter did not stop within %s, cancelling task %s...\n", cancelGracePeriod, task.Name)
+				if err := client.CancelTask(task.GUID); err != nil {
+					fmt.Printf("Error cancelling prompter task: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		} else if !waitForAppStopped(client, prompterGUID, cancelGracePeriod) {
+			fmt.Printf("Prompter did not stop within %s, stopping app '%s'...\n", cancelGracePeriod, prompterName)
+			if err := client.StopApp(prompterGUID); err != nil {
+				fmt.Printf("Error stopping prompter app: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// The prompter cleans up after itself, unless it was stopped before it could
+	deleted, err := client.DeletePendingPackages(appGUID, state.RunID)
+	if err != nil {
+		fmt.Printf("Warning: failed to delete incomplete packages: %v\n", err)
+	}
+	for _, guid := range deleted {
+		fmt.Printf("Deleted incomplete package %s\n", cfclient.ShortHash(guid))
+	}
+
+	deployer := prompter.NewAppDeployer(cliConnection, prompterName)
+	if err := deployer.Attach(apiEndpoint, token, currentSpace.Guid, *state); err == nil {
+		if err := deployer.RemoveRunCredentials(); err != nil {
+			fmt.Printf("Warning: failed to remove run credentials: %v\n", err)
+		}
+		if err := deployer.ReleaseLock(); err != nil {
+			fmt.Printf("Warning: failed to release prompter lock: %v\n", err)
+		}
+	}
+
+	// Record the cancellation unless the prompter did, or the run ended in the meantime
+	if current, _, err := loadRunState(client, prompterGUID, appGUID); err == nil && current != nil && current.RunID == state.RunID && !current.Finished() {
+		current.Status = runstate.StatusCancelled
+		current.UpdatedAt = time.Now().UTC()
+		if err := client.UpdateAppAnnotations(prompterGUID, current.Annotations()); err != nil {
+			fmt.Printf("Warning: failed to record cancellation: %v\n", err)
+		}
+	}
+
+	// The prompter clears the request when its run ends, unless it was stopped before
+	if annotations, err := client.GetAppAnnotations(prompterGUID); err == nil && annotations[runstate.KeyCancel] == state.RunID {
+		if err := client.UpdateAppAnnotations(prompterGUID, map[string]string{runstate.KeyCancel: ""}); err != nil {
+			fmt.Printf("Warning: failed to clear cancellation request: %v\n", err)
+		}
+	}
+
+	fmt.Println("OK")
+	fmt.Println()
+	fmt.Printf("Prompt run %s has been cancelled.\n", state.RunID)
+}
+
+// waitForAppStopped polls the app state and returns whether the app stopped within timeout
+func waitForAppStopped(client *cfclient.Client, appGUID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if app, err := client.GetApp(appGUID); err == nil && app.State == "STOPPED" {
+			return true
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
+
+// waitForTaskFinished polls the task state and returns whether the task ended within timeout
+func waitForTaskFinished(client *cfclient.Client, taskGUID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if task, err := client.GetTask(taskGUID); err == nil && cfclient.TaskFinished(task.State) {
+			return true
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+)
+
+// PromptDiffOptions holds the parsed arguments of the prompt-diff command
+type PromptDiffOptions struct {
+	App       string
+	Hash      string
+	OtherHash string
+	Stat      bool
+	NameOnly  bool
+	// Variants is a variant group whose packages are each compared to their base package
+	Variants string
+}
+
+// ParsePromptDiffArgs parses command line arguments for prompt-diff and returns the options and whether parsing failed
+func ParsePromptDiffArgs(args []string) (opts PromptDiffOptions, failed bool) {
+	var nonFlagArgs []string
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--stat":
+			opts.Stat = true
+		case args[i] == "--name-only":
+			opts.NameOnly = true
+		case args[i] == "--variants" && i+1 < len(args):
+			opts.Variants = args[i+1]
+			i++
+		default:
+			nonFlagArgs = append(nonFlagArgs, args[i])
+		}
+	}
+
+	if opts.Stat && opts.NameOnly {
+		return PromptDiffOptions{}, true
+	}
+
+	if opts.Variants != "" {
+		if len(nonFlagArgs) != 1 {
+			return PromptDiffOptions{}, true
+		}
+		opts.App = nonFlagArgs[0]
+		return opts, false
+	}
+
+	if len(nonFlagArgs) < 2 || len(nonFlagArgs) > 3 {
+		return PromptDiffOptions{}, true
+	}
+
+	opts.App = nonFlagArgs[0]
+	opts.Hash = nonFlagArgs[1]
+	if len(nonFlagArgs) == 3 {
+		opts.OtherHash = nonFlagArgs[2]
+	}
+
+	return opts, false
+}
+
+func PromptDiffCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptDiffArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-diff <APP_NAME> <PACKAGE_HASH> [OTHER_PACKAGE_HASH] [--stat | --name-only]")
+		fmt.Println("   or: cf prompt-diff <APP_NAME> --variants GROUP [--stat | --name-only]")
+		os.Exit(1)
+	}
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+	client.SetRegistryCredentials(registryCredentials())
+
+	appGUID, err := client.GetAppGUID(opts.App, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", opts.App, err)
+		os.Exit(1)
+	}
+
+	if opts.Variants != "" {
+		diffVariants(client, appGUID, opts)
+		return
+	}
+
+	pkg, err := client.FindPackageByShortHash(appGUID, opts.Hash)
+	if err != nil {
+		fmt.Printf("Error finding package: %v\n", err)
+		os.Exit(1)
+	}
+
+	// With two hashes the diff goes from the first to the second, like 'git diff A B'.
+	// With a single hash it shows what that package changes compared to the deployed one.
+	var oldPkg, newPkg *resource.Package
+	if opts.OtherHash != "" {
+		otherPkg, err := client.FindPackageByShortHash(appGUID, opts.OtherHash)
+		if err != nil {
+			fmt.Printf("Error finding package: %v\n", err)
+			os.Exit(1)
+		}
+		oldPkg, newPkg = pkg, otherPkg
+	} else {
+		currentPackageGUID, err := client.GetCurrentDropletPackageGUID(appGUID)
+		if err != nil || currentPackageGUID == "" {
+			fmt.Printf("Error: app '%s' has no current droplet to compare against\n", opts.App)
+			fmt.Println("Specify a second package hash to compare two revisions")
+			os.Exit(1)
+		}
+
+		currentPkg, err := client.FindPackageByShortHash(appGUID, cfclient.ShortHash(currentPackageGUID))
+		if err != nil {
+			fmt.Printf("Error finding current package: %v\n", err)
+			os.Exit(1)
+		}
+		oldPkg, newPkg = currentPkg, pkg
+	}
+
+	oldFiles, err := loadPackageFiles(client, oldPkg)
+	if err != nil {
+		fmt.Printf("Error loading package %s: %v\n", cfclient.ShortHash(oldPkg.GUID), err)
+		os.Exit(1)
+	}
+
+	newFiles, err := loadPackageFiles(client, newPkg)
+	if err != nil {
+		fmt.Printf("Error loading package %s: %v\n", cfclient.ShortHash(newPkg.GUID), err)
+		os.Exit(1)
+	}
+
+	writeChanges(oldPkg, newPkg, diffTrees(oldFiles, newFiles), opts)
+}
+
+// writeChanges prints the changes between two packages in the selected output mode
+func writeChanges(oldPkg, newPkg *resource.Package, changes []fileChange, opts PromptDiffOptions) {
+	if len(changes) == 0 {
+		fmt.Printf("No differences between %s and %s\n", cfclient.ShortHash(oldPkg.GUID), cfclient.ShortHash(newPkg.GUID))
+		return
+	}
+
+	switch {
+	case opts.Stat:
+		writeDiffStat(os.Stdout, changes)
+	case opts.NameOnly:
+		writeNameOnly(os.Stdout, changes)
+	default:
+		writeUnifiedDiff(os.Stdout, changes)
+	}
+}
+
+// diffVariants prints what each package of a variant group changes compared to the
+// base package the variants were created from
+func diffVariants(client *cfclient.Client, appGUID string, opts PromptDiffOptions) {
+	packages, err := client.ListPackagesWithPrompts(appGUID)
+	if err != nil {
+		fmt.Printf("Error listing packages: %v\n", err)
+		os.Exit(1)
+	}
+
+	variants := findVariants(packages, opts.Variants)
+	if len(variants) == 0 {
+		fmt.Printf("Error: no variants found in group '%s'\n", opts.Variants)
+		os.Exit(1)
+	}
+
+	baseFiles := make(map[string]map[string][]byte)
+	for i, variant := range variants {
+		parentGUID, ok := client.GetParentPackageGUID(variant)
+		if !ok {
+			fmt.Printf("Error: variant %s has no base package\n", cfclient.ShortHash(variant.GUID))
+			os.Exit(1)
+		}
+		basePkg, err := client.GetPackage(parentGUID)
+		if err != nil {
+			fmt.Printf("Error finding base package: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Variants normally share their base package, which is only downloaded once
+		oldFiles, ok := baseFiles[parentGUID]
+		if !ok {
+			oldFiles, err = loadPackageFiles(client, basePkg)
+			if err != nil {
+				fmt.Printf("Error loading package %s: %v\n", cfclient.ShortHash(basePkg.GUID), err)
+				os.Exit(1)
+			}
+			baseFiles[parentGUID] = oldFiles
+		}
+
+		newFiles, err := loadPackageFiles(client, variant)
+		if err != nil {
+			fmt.Printf("Error loading package %s: %v\n", cfclient.ShortHash(variant.GUID), err)
+			os.Exit(1)
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("=== %s: %s -> %s ===\n", variantLabel(variant), cfclient.ShortHash(basePkg.GUID), cfclient.ShortHash(variant.GUID))
+		writeChanges(basePkg, variant, diffTrees(oldFiles, newFiles), opts)
+	}
+}
+
+// loadPackageFiles downloads a package into a temporary directory and reads its source files
+func loadPackageFiles(client *cfclient.Client, pkg *resource.Package) (map[string][]byte, error) {
+	tempDir, err := os.MkdirTemp("", "cf-prompt-diff-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir, err := client.DownloadPackageSource(pkg, tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download package: %w", err)
+	}
+
+	return loadTree(sourceDir)
+}
@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+)
+
+// doctorTaskTimeout is how long the checks run inside the prompter may take
+const doctorTaskTimeout = 3 * time.Minute
+
+// doctorCheck is the result of a single check of 'cf prompt-doctor'
+type doctorCheck struct {
+	Name   string
+	Passed bool
+	// Skipped is set if the check could not run because an earlier check failed
+	Skipped bool
+	Detail  string
+	// Hint tells how to fix a failed check
+	Hint string
+}
+
+func (c doctorCheck) result() string {
+	switch {
+	case c.Skipped:
+		return "skipped"
+	case c.Passed:
+		return "pass"
+	default:
+		return "FAIL"
+	}
+}
+
+func PromptDoctorCommand(cliConnection plugin.CliConnection, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-doctor <APP_NAME>")
+		os.Exit(1)
+	}
+
+	appName := args[0]
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Checking the prompt setup of app %s...\n\n", appName)
+
+	checks := runDoctorChecks(client, apiEndpoint, appName, currentSpace.Guid)
+	if !printDoctorChecks(checks) {
+		os.Exit(1)
+	}
+}
+
+// runDoctorChecks runs the checks in order. Checks that depend on one that failed are
+// reported as skipped.
+func runDoctorChecks(client *cfclient.Client, apiEndpoint, appName, spaceGUID string) []doctorCheck {
+	var checks []doctorCheck
+	skip := func(names ...string) []doctorCheck {
+		for _, name := range names {
+			checks = append(checks, doctorCheck{Name: name, Skipped: true})
+		}
+		return checks
+	}
+
+	if err := client.Ping(); err != nil {
+		checks = append(checks, doctorCheck{Name: "CF API", Detail: err.Error(), Hint: "check the endpoint with 'cf api' and log in again with 'cf login'"})
+		return skip("app", "registry access", "prompter", "prompter version", "CF API from prompter", "OpenCode download", "provider credentials")
+	}
+	checks = append(checks, doctorCheck{Name: "CF API", Passed: true, Detail: apiEndpoint})
+
+	appGUID, err := client.GetAppGUID(appName, spaceGUID)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "app", Detail: err.Error(), Hint: "check the app name and the targeted space with 'cf apps'"})
+		return skip("registry access", "prompter", "prompter version", "CF API from prompter", "OpenCode download", "provider credentials")
+	}
+	checks = append(checks, doctorCheck{Name: "app", Passed: true, Detail: appName})
+
+	checks = append(checks, registryCheck(client, appName, appGUID))
+
+	prompterName, prompterGUID, err := findPrompter(client, appName, spaceGUID)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "prompter", Detail: err.Error(), Hint: fmt.Sprintf("run 'cf prompt-init %s' or 'cf prompt-init --shared'", appName)})
+		return skip("prompter version", "CF API from prompter", "OpenCode download", "provider credentials")
+	}
+
+	upgradeCommand := fmt.Sprintf("cf prompt-init %s --upgrade", appName)
+	if prompterName == prompter.SharedPrompterName {
+		upgradeCommand = "cf prompt-init --shared --upgrade"
+	}
+
+	// An app without a current droplet is not an error of the API, its GUID is empty
+	dropletGUID, err := client.GetCurrentDropletGUID(prompterGUID)
+	if err != nil || dropletGUID == "" {
+		detail := fmt.Sprintf("app '%s' has no droplet", prompterName)
+		if err != nil {
+			detail += fmt.Sprintf(": %v", err)
+		}
+		checks = append(checks, doctorCheck{Name: "prompter", Detail: detail, Hint: fmt.Sprintf("stage the prompter with '%s'", upgradeCommand)})
+		return skip("prompter version", "CF API from prompter", "OpenCode download", "provider credentials")
+	}
+	checks = append(checks, doctorCheck{Name: "prompter", Passed: true, Detail: prompterName})
+
+	app, err := client.GetApp(prompterGUID)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "prompter version", Detail: err.Error(), Hint: "retry, the CF API may be overloaded"})
+	} else {
+		label, _, err := client.GetAppLabel(prompterGUID, cfclient.LabelPrompterVersion)
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "prompter version", Detail: err.Error(), Hint: "retry, the CF API may be overloaded"})
+		} else {
+			checks = append(checks, prompterVersionCheck(label, prompter.InitOptions{}.Version(), app.Lifecycle.Type == "docker", upgradeCommand))
+		}
+	}
+
+	return append(checks, prompterTaskChecks(client, apiEndpoint, prompterName, prompterGUID, upgradeCommand)...)
+}
+
+// registryCheck makes sure the latest package of the app can be pulled with the registry
+// credentials the prompter is given
+func registryCheck(client *cfclient.Client, appName, appGUID string) doctorCheck {
+	check := doctorCheck{Name: "registry access"}
+
+	pkg, err := client.GetLatestPackage(appGUID)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = fmt.Sprintf("push the app with 'cf push %s' first", appName)
+		return check
+	}
+
+	imageURL, ok := cfclient.PackageImage(pkg)
+	if !ok {
+		check.Passed = true
+		check.Detail = "package bits are stored by CF, no registry needed"
+		return check
+	}
+
+	username, password := registryCredentials()
+	client.SetRegistryCredentials(username, password)
+	if err := client.CheckImageAccess(imageURL); err != nil {
+		check.Detail = err.Error()
+		check.Hint = "set REGISTRY_USERNAME and REGISTRY_PASSWORD to credentials that can pull from the package registry"
+		if os.Getenv("REGISTRY_USERNAME") == "" || os.Getenv("REGISTRY_PASSWORD") == "" {
+			check.Hint += ", the default credentials of the local development registry are in use"
+		}
+		return check
+	}
+
+	check.Passed = true
+	check.Detail = imageURL
+	return check
+}
+
+// prompterVersionCheck compares the version label of the prompter with the version this
+// plugin deploys. The image of a Docker based prompter is chosen by the user, so any
+// version of it passes.
+func prompterVersionCheck(label, expected string, docker bool, upgradeCommand string) doctorCheck {
+	check := doctorCheck{Name: "prompter version"}
+
+	switch {
+	case docker:
+		check.Passed = true
+		check.Detail = "runs a Docker image"
+		if label != "" {
+			check.Detail += fmt.Sprintf(" (version %s)", label)
+		}
+	case label == expected:
+		check.Passed = true
+		check.Detail = label
+	case label == "":
+		check.Detail = "unknown version, deployed by an older plugin"
+		check.Hint = fmt.Sprintf("run '%s'", upgradeCommand)
+	default:
+		check.Detail = fmt.Sprintf("version %s, this plugin deploys %s", label, expected)
+		check.Hint = fmt.Sprintf("run '%s'", upgradeCommand)
+	}
+
+	return check
+}
+
+// taskCheckNames are the names of the checks 'prompter doctor' runs, in order
+var taskCheckNames = []string{"CF API from prompter", "OpenCode download", "provider credentials"}
+
+// taskChecksFailed returns the checks of 'prompter doctor' when the task gave no results:
+// the first one fails, the others are skipped
+func taskChecksFailed(detail, hint string) []doctorCheck {
+	checks := []doctorCheck{{Name: taskCheckNames[0], Detail: detail, Hint: hint}}
+	for _, name := range taskCheckNames[1:] {
+		checks = append(checks, doctorCheck{Name: name, Skipped: true})
+	}
+	return checks
+}
+
+// prompterTaskChecks runs 'prompter doctor' as a task on the prompter, so the CF API, the
+// download of OpenCode and the provider credentials are checked from where the agent
+// runs. The task is given the CF API endpoint a run of the prompter would use.
+func prompterTaskChecks(client *cfclient.Client, apiEndpoint, prompterName, prompterGUID, upgradeCommand string) []doctorCheck {
+	taskName := fmt.Sprintf("doctor-%d", time.Now().Unix())
+	started := time.Now()
+
+	fmt.Printf("Running checks in task %s on prompter app '%s'...\n", taskName, prompterName)
+	task, err := client.CreateTask(prompterGUID, taskName, prompter.DoctorTaskCommand(apiEndpoint))
+	if err != nil {
+		return taskChecksFailed(err.Error(), fmt.Sprintf("make sure the space quota leaves room for a task of '%s'", prompterName))
+	}
+
+	if !waitForTaskFinished(client, task.GUID, doctorTaskTimeout) {
+		client.CancelTask(task.GUID)
+		return taskChecksFailed(fmt.Sprintf("task %s did not finish within %s", taskName, doctorTaskTimeout), fmt.Sprintf("check the task with 'cf logs %s --recent'", prompterName))
+	}
+
+	// Log Cache may lag behind the end of the task
+	var envelopes []cfclient.LogEnvelope
+	for attempt := 0; attempt < 5; attempt++ {
+		envelopes, err = client.ReadLogs(prompterGUID, started)
+		if err == nil && len(taskCheckEvents(envelopes, taskName)) == len(taskCheckNames) {
+			break
+		}
+		time.Sleep(2 * time.Second)
+	}
+	if err != nil {
+		return taskChecksFailed(err.Error(), fmt.Sprintf("check the task with 'cf logs %s --recent'", prompterName))
+	}
+
+	return evaluateTaskChecks(taskCheckEvents(envelopes, taskName), prompterName, upgradeCommand)
+}
+
+// taskCheckEvents returns the check events the task wrote to the log, by check name
+func taskCheckEvents(envelopes []cfclient.LogEnvelope, taskName string) map[string]events.Event {
+	results := make(map[string]events.Event)
+	for _, envelope := range envelopes {
+		if envelope.SourceType != "APP/TASK/"+taskName {
+			continue
+		}
+		if event, ok := events.Parse(envelope.Message); ok && event.Type == events.TypeCheck {
+			results[event.Check] = event
+		}
+	}
+	return results
+}
+
+// evaluateTaskChecks turns the check events of 'prompter doctor' into check results with
+// hints. A prompter without the doctor mode reports no checks at all.
+func evaluateTaskChecks(results map[string]events.Event, prompterName, upgradeCommand string) []doctorCheck {
+	if len(results) == 0 {
+		return taskChecksFailed("the prompter reported no checks", fmt.Sprintf("the prompter may predate prompt-doctor, run '%s', or check the task with 'cf logs %s --recent'", upgradeCommand, prompterName))
+	}
+
+	checks := []struct {
+		event string
+		name  string
+		hint  string
+	}{
+		{events.CheckCFAPI, taskCheckNames[0], fmt.Sprintf("let the prompter reach the CF API, e.g. with a security group that allows egress to it (see 'cf security-groups'), or check HTTPS_PROXY and NO_PROXY with 'cf env %s'", prompterName)},
+		{events.CheckOpencodeDownload, taskCheckNames[1], fmt.Sprintf("let the prompter reach github.com, e.g. with 'cf set-env %s HTTPS_PROXY URL', or point it to a mirror with 'cf set-env %s OPENCODE_RELEASE_URL URL'", prompterName, prompterName)},
+		{events.CheckProviderCredentials, taskCheckNames[2], fmt.Sprintf("bind a user-provided service tagged cf-prompt holding the API key, e.g. created with cf create-user-provided-service llm-credentials -p '{\"ANTHROPIC_API_KEY\":\"...\"}' -t cf-prompt, with 'cf bind-service %s llm-credentials'", prompterName)},
+	}
+
+	var evaluated []doctorCheck
+	for _, c := range checks {
+		event, ok := results[c.event]
+		switch {
+		case !ok:
+			evaluated = append(evaluated, doctorCheck{Name: c.name, Detail: "not reported by the prompter", Hint: fmt.Sprintf("check the task with 'cf logs %s --recent'", prompterName)})
+		case event.Passed:
+			evaluated = append(evaluated, doctorCheck{Name: c.name, Passed: true, Detail: event.Message})
+		default:
+			evaluated = append(evaluated, doctorCheck{Name: c.name, Detail: event.Message, Hint: c.hint})
+		}
+	}
+	return evaluated
+}
+
+// printDoctorChecks prints the results followed by the hints of the failed checks and
+// returns whether all checks passed
+func printDoctorChecks(checks []doctorCheck) bool {
+	table := newSimpleTable([]string{"check", "result", "details"})
+	passed := true
+	for _, check := range checks {
+		table.addRow(check.Name, check.result(), check.Detail)
+		if !check.Passed {
+			passed = false
+		}
+	}
+	fmt.Println()
+	table.print()
+
+	if passed {
+		fmt.Println("\nAll checks passed")
+		return true
+	}
+
+	fmt.Println("\nTo fix:")
+	for _, check := range checks {
+		if !check.Passed && !check.Skipped {
+			fmt.Printf("  %s: %s\n", check.Name, check.Hint)
+		}
+	}
+	return false
+}
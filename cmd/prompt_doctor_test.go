@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+)
+
+func TestPrompterVersionCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		docker   bool
+		passed   bool
+		withHint bool
+	}{
+		{
+			name:   "Current version",
+			label:  "0123456789ab",
+			passed: true,
+		},
+		{
+			name:     "Other version",
+			label:    "ba9876543210",
+			withHint: true,
+		},
+		{
+			name:     "No version label",
+			withHint: true,
+		},
+		{
+			name:   "Docker image",
+			label:  "ba9876543210",
+			docker: true,
+			passed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := prompterVersionCheck(tt.label, "0123456789ab", tt.docker, "cf prompt-init test --upgrade")
+
+			if check.Passed != tt.passed {
+				t.Errorf("Expected passed=%v, got %v (%s)", tt.passed, check.Passed, check.Detail)
+			}
+			if (check.Hint != "") != tt.withHint {
+				t.Errorf("Expected hint=%v, got %q", tt.withHint, check.Hint)
+			}
+		})
+	}
+}
+
+func TestDoctorTaskChecks(t *testing.T) {
+	logLine := func(sourceType string, event events.Event) cfclient.LogEnvelope {
+		event.Type = events.TypeCheck
+		content, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfclient.LogEnvelope{SourceType: sourceType, Message: events.Marker + string(content)}
+	}
+
+	tests := []struct {
+		name      string
+		envelopes []cfclient.LogEnvelope
+		expected  []string
+	}{
+		{
+			name: "All passed",
+			envelopes: []cfclient.LogEnvelope{
+				{SourceType: "APP/TASK/doctor-1", Message: "Installing opencode"},
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckCFAPI, Passed: true, Message: "https://api.example.com"}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckOpencodeDownload, Passed: true}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckProviderCredentials, Passed: true, Message: "ANTHROPIC_API_KEY"}),
+			},
+			expected: []string{"pass", "pass", "pass"},
+		},
+		{
+			name: "CF API unreachable",
+			envelopes: []cfclient.LogEnvelope{
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckCFAPI, Message: "failed to reach CF API at https://api.example.com"}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckOpencodeDownload, Passed: true}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckProviderCredentials, Passed: true}),
+			},
+			expected: []string{"FAIL", "pass", "pass"},
+		},
+		{
+			name: "Download failed",
+			envelopes: []cfclient.LogEnvelope{
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckCFAPI, Passed: true}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckOpencodeDownload, Message: "failed to reach github.com"}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckProviderCredentials, Passed: true}),
+			},
+			expected: []string{"pass", "FAIL", "pass"},
+		},
+		{
+			name: "Checks of another task",
+			envelopes: []cfclient.LogEnvelope{
+				logLine("APP/TASK/doctor-0", events.Event{Check: events.CheckCFAPI, Passed: true}),
+				logLine("APP/TASK/doctor-0", events.Event{Check: events.CheckOpencodeDownload, Passed: true}),
+				logLine("APP/TASK/doctor-0", events.Event{Check: events.CheckProviderCredentials, Passed: true}),
+			},
+			expected: []string{"FAIL", "skipped", "skipped"},
+		},
+		{
+			name: "Check not reported",
+			envelopes: []cfclient.LogEnvelope{
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckOpencodeDownload, Passed: true}),
+				logLine("APP/TASK/doctor-1", events.Event{Check: events.CheckProviderCredentials, Passed: true}),
+			},
+			expected: []string{"FAIL", "pass", "pass"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := evaluateTaskChecks(taskCheckEvents(tt.envelopes, "doctor-1"), "test-prompter", "cf prompt-init test --upgrade")
+
+			if len(checks) != len(tt.expected) {
+				t.Fatalf("Expected %d checks, got %d", len(tt.expected), len(checks))
+			}
+			for i, check := range checks {
+				if check.result() != tt.expected[i] {
+					t.Errorf("Expected %s to be %s, got %s", check.Name, tt.expected[i], check.result())
+				}
+				if check.result() == "FAIL" && check.Hint == "" {
+					t.Errorf("Expected a hint for failed check %s", check.Name)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,154 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 
 	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+	"gopkg.in/yaml.v3"
 )
 
+// PromptInitOptions holds the parsed arguments of the prompt-init command
+type PromptInitOptions struct {
+	App string
+	// Shared deploys the prompter of the space, which serves every app without a prompter of its own
+	Shared bool
+	// Upgrade stages the prompter of this plugin version in the existing prompter app
+	// instead of replacing the app, so its configuration is kept
+	Upgrade bool
+	// Config is a YAML file with the settings of the prompter app, Prompter holds the
+	// settings given as flags, which take precedence
+	Config   string
+	Prompter prompter.InitOptions
+}
+
+// ParsePromptInitArgs parses command line arguments for prompt-init and returns the options and whether parsing failed
+func ParsePromptInitArgs(args []string) (opts PromptInitOptions, failed bool) {
+	var nonFlagArgs []string
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--shared" {
+			opts.Shared = true
+		} else if args[i] == "--upgrade" {
+			opts.Upgrade = true
+		} else if args[i] == "--config" && i+1 < len(args) {
+			opts.Config = args[i+1]
+			i++
+		} else if args[i] == "--memory" && i+1 < len(args) {
+			opts.Prompter.Memory = args[i+1]
+			i++
+		} else if args[i] == "--disk" && i+1 < len(args) {
+			opts.Prompter.Disk = args[i+1]
+			i++
+		} else if args[i] == "--buildpack" && i+1 < len(args) {
+			opts.Prompter.Buildpack = args[i+1]
+			i++
+		} else if args[i] == "--stack" && i+1 < len(args) {
+			opts.Prompter.Stack = args[i+1]
+			i++
+		} else if args[i] == "--env" && i+1 < len(args) {
+			name, value, ok := strings.Cut(args[i+1], "=")
+			if !ok || name == "" {
+				return PromptInitOptions{}, true
+			}
+			if opts.Prompter.Env == nil {
+				opts.Prompter.Env = make(map[string]string)
+			}
+			opts.Prompter.Env[name] = value
+			i++
+		} else if args[i] == "--service" && i+1 < len(args) {
+			opts.Prompter.Services = append(opts.Prompter.Services, args[i+1])
+			i++
+		} else if args[i] == "--docker-image" && i+1 < len(args) {
+			opts.Prompter.DockerImage = args[i+1]
+			i++
+		} else if args[i] == "--docker-username" && i+1 < len(args) {
+			opts.Prompter.DockerUsername = args[i+1]
+			i++
+		} else {
+			nonFlagArgs = append(nonFlagArgs, args[i])
+		}
+	}
+
+	switch {
+	case opts.Shared && len(nonFlagArgs) == 0:
+	case !opts.Shared && len(nonFlagArgs) == 1 && prompter.PrompterName(nonFlagArgs[0]) != prompter.SharedPrompterName:
+		// The prompter of an app named "shared" would be the shared prompter
+		opts.App = nonFlagArgs[0]
+	default:
+		return PromptInitOptions{}, true
+	}
+
+	// An upgrade keeps the settings of the app, only the image of a Docker based prompter changes
+	if opts.Upgrade {
+		settings := opts.Prompter
+		settings.DockerImage, settings.DockerUsername = "", ""
+		if opts.Config != "" || !reflect.DeepEqual(settings, prompter.InitOptions{}) {
+			return PromptInitOptions{}, true
+		}
+	}
+
+	return opts, false
+}
+
+// ParsePrompterConfig parses a prompter config file for prompt-init
+func ParsePrompterConfig(data []byte) (prompter.InitOptions, error) {
+	var config prompter.InitOptions
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return prompter.InitOptions{}, fmt.Errorf("failed to parse prompter config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return prompter.InitOptions{}, err
+	}
+	return config, nil
+}
+
 func PromptInitCommand(cliConnection plugin.CliConnection, args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: Missing required argument APP_NAME")
-		fmt.Println("Usage: cf prompt-init <APP_NAME>")
+	opts, failed := ParsePromptInitArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-init <APP_NAME> | --shared [--config FILE] [--memory SIZE] [--disk SIZE]")
+		fmt.Println("       [--buildpack BUILDPACK] [--stack STACK] [--env NAME=VALUE] [--service SERVICE] [--docker-image IMAGE [--docker-username USER]]")
+		fmt.Println("   or: cf prompt-init <APP_NAME> | --shared --upgrade [--docker-image IMAGE [--docker-username USER]]")
 		os.Exit(1)
 	}
 
-	appName := args[0]
-	fmt.Printf("Initializing prompter for app: %s\n", appName)
+	settings := opts.Prompter
+	if opts.Config != "" {
+		content, err := os.ReadFile(opts.Config)
+		if err != nil {
+			fmt.Printf("Error reading prompter config: %v\n", err)
+			os.Exit(1)
+		}
+		config, err := ParsePrompterConfig(content)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings = config.Merge(opts.Prompter)
+	}
+	if err := settings.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var deployer *prompter.PrompterInitDeployer
+	if opts.Shared {
+		fmt.Println("Initializing shared prompter for the space")
+		deployer = prompter.NewSharedPrompterInitDeployer(cliConnection)
+	} else {
+		fmt.Printf("Initializing prompter for app: %s\n", opts.App)
+		deployer = prompter.NewPrompterInitDeployer(cliConnection, opts.App)
+	}
 
 	apiEndpoint, err := cliConnection.ApiEndpoint()
 	if err != nil {
@@ -30,12 +162,44 @@ func PromptInitCommand(cliConnection plugin.CliConnection, args []string) {
 		os.Exit(1)
 	}
 
-	deployer := prompter.NewPrompterInitDeployer(cliConnection, appName)
+	if opts.Upgrade {
+		upgraded, err := deployer.UpgradePrompterApp(apiEndpoint, token, settings)
+		if err != nil {
+			fmt.Printf("Error upgrading prompter app: %v\n", err)
+			os.Exit(1)
+		}
+		if upgraded {
+			fmt.Printf("Prompter app '%s' upgraded successfully\n", deployer.PrompterName())
+		}
+		return
+	}
 
-	if err := deployer.DeployPrompterApp(apiEndpoint, token); err != nil {
+	if err := deployer.DeployPrompterApp(apiEndpoint, token, settings); err != nil {
 		fmt.Printf("Error deploying prompter app: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Prompter app '%s-prompter' deployed successfully\n", appName)
+	fmt.Printf("Prompter app '%s' deployed successfully\n", deployer.PrompterName())
+	if opts.Shared {
+		fmt.Println("It runs the prompts of every app in the space that has no prompter of its own.")
+	}
+}
+
+// findPrompter returns the name and GUID of the prompter that runs the prompts of an
+// app: its own prompter if it has one, otherwise the shared prompter of the space
+func findPrompter(client *cfclient.Client, app, spaceGUID string) (name, guid string, err error) {
+	for _, name := range []string{prompter.PrompterName(app), prompter.SharedPrompterName} {
+		guid, err := client.GetAppGUID(name, spaceGUID)
+		// Only a missing prompter falls through, the credentials of a run must not end
+		// up on the shared prompter because of a failed lookup
+		var notFound *cfclient.AppNotFoundError
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to find prompter app '%s': %w", name, err)
+		}
+		return name, guid, nil
+	}
+	return "", "", fmt.Errorf("prompter app '%s' does not exist, run 'cf prompt-init %s' or 'cf prompt-init --shared' first", prompter.PrompterName(app), app)
 }
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+)
+
+func TestPromptInitArgumentParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expected   PromptInitOptions
+		shouldFail bool
+	}{
+		{
+			name:     "App",
+			args:     []string{"test"},
+			expected: PromptInitOptions{App: "test"},
+		},
+		{
+			name:     "Shared",
+			args:     []string{"--shared"},
+			expected: PromptInitOptions{Shared: true},
+		},
+		{
+			name: "Resources and buildpack",
+			args: []string{"test", "--memory", "2G", "--disk", "8G", "--buildpack", "binary_buildpack", "--stack", "cflinuxfs4"},
+			expected: PromptInitOptions{App: "test", Prompter: prompter.InitOptions{
+				Memory: "2G", Disk: "8G", Buildpack: "binary_buildpack", Stack: "cflinuxfs4",
+			}},
+		},
+		{
+			name: "Env and services",
+			args: []string{"--shared", "--env", "HTTPS_PROXY=http://proxy.example.com:3128", "--service", "llm-keys", "--service", "proxy"},
+			expected: PromptInitOptions{Shared: true, Prompter: prompter.InitOptions{
+				Env:      map[string]string{"HTTPS_PROXY": "http://proxy.example.com:3128"},
+				Services: []string{"llm-keys", "proxy"},
+			}},
+		},
+		{
+			name:     "Docker image",
+			args:     []string{"test", "--docker-image", "registry.example.com/prompter:1.2", "--docker-username", "robot"},
+			expected: PromptInitOptions{App: "test", Prompter: prompter.InitOptions{DockerImage: "registry.example.com/prompter:1.2", DockerUsername: "robot"}},
+		},
+		{
+			name:     "Config file",
+			args:     []string{"test", "--config", "prompter.yml", "--docker-username", "robot"},
+			expected: PromptInitOptions{App: "test", Config: "prompter.yml", Prompter: prompter.InitOptions{DockerUsername: "robot"}},
+		},
+		{
+			name:       "App named like the shared prompter",
+			args:       []string{"shared"},
+			shouldFail: true,
+		},
+		{
+			name:       "Env without value",
+			args:       []string{"test", "--env", "MODEL"},
+			shouldFail: true,
+		},
+		{
+			name:     "Upgrade",
+			args:     []string{"test", "--upgrade"},
+			expected: PromptInitOptions{App: "test", Upgrade: true},
+		},
+		{
+			name:     "Upgrade Docker image",
+			args:     []string{"--shared", "--upgrade", "--docker-image", "registry.example.com/prompter:1.3"},
+			expected: PromptInitOptions{Shared: true, Upgrade: true, Prompter: prompter.InitOptions{DockerImage: "registry.example.com/prompter:1.3"}},
+		},
+		{
+			name:       "Upgrade with settings",
+			args:       []string{"test", "--upgrade", "--memory", "2G"},
+			shouldFail: true,
+		},
+		{
+			name:       "Upgrade with config file",
+			args:       []string{"test", "--upgrade", "--config", "prompter.yml"},
+			shouldFail: true,
+		},
+		{
+			name:       "Shared with app",
+			args:       []string{"test", "--shared"},
+			shouldFail: true,
+		},
+		{
+			name:       "Two apps",
+			args:       []string{"test", "other"},
+			shouldFail: true,
+		},
+		{
+			name:       "No arguments",
+			args:       []string{},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptInitArgs(tt.args)
+
+			if tt.shouldFail != failed {
+				t.Fatalf("Expected failed=%v, got %v", tt.shouldFail, failed)
+			}
+			if !tt.shouldFail && !reflect.DeepEqual(opts, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestPromptInitSettingsValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Invalid memory",
+			args:     []string{"test", "--memory", "1GiB"},
+			expected: "invalid memory '1GiB'",
+		},
+		{
+			name:     "Docker image with buildpack",
+			args:     []string{"test", "--docker-image", "prompter:1.2", "--buildpack", "binary_buildpack"},
+			expected: "a docker image cannot be combined with a buildpack or stack",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptInitArgs(tt.args)
+			if failed {
+				t.Fatal("Expected the arguments to parse, the settings are validated separately")
+			}
+
+			err := opts.Prompter.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestPrompterConfig(t *testing.T) {
+	config, err := ParsePrompterConfig([]byte(`
+memory: 2G
+disk: 8G
+env:
+  HTTPS_PROXY: http://proxy.example.com:3128
+services: [llm-keys]
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	settings := config.Merge(prompter.InitOptions{
+		Disk:     "16G",
+		Env:      map[string]string{"MODEL": "claude-sonnet-4-5"},
+		Services: []string{"proxy"},
+	})
+	expected := prompter.InitOptions{
+		Memory:   "2G",
+		Disk:     "16G",
+		Env:      map[string]string{"HTTPS_PROXY": "http://proxy.example.com:3128", "MODEL": "claude-sonnet-4-5"},
+		Services: []string{"llm-keys", "proxy"},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, settings)
+	}
+
+	manifest, err := settings.Manifest("test-prompter")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, line := range []string{"name: test-prompter", "memory: 2G", "disk_quota: 16G", "- paketo-buildpacks/procfile", "MODEL: claude-sonnet-4-5", "- proxy"} {
+		if !strings.Contains(string(manifest), line) {
+			t.Errorf("Expected manifest to contain %q:\n%s", line, manifest)
+		}
+	}
+
+	docker, err := prompter.InitOptions{DockerImage: "registry.example.com/prompter:1.2"}.Manifest("test-prompter")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(docker), "image: registry.example.com/prompter:1.2") || strings.Contains(string(docker), "buildpacks") {
+		t.Errorf("Unexpected docker manifest:\n%s", docker)
+	}
+
+	if _, err := ParsePrompterConfig([]byte("memroy: 2G\n")); err == nil {
+		t.Error("Expected an error for an unknown setting")
+	}
+}
+
+func TestPrompterVersion(t *testing.T) {
+	image := prompter.InitOptions{DockerImage: "registry.example.com/prompter:1.2"}
+	if image.Version() == (prompter.InitOptions{}).Version() {
+		t.Error("Expected a Docker based prompter to have another version than the embedded binary")
+	}
+	if image.Version() != (prompter.InitOptions{DockerImage: "registry.example.com/prompter:1.2", Memory: "4G"}).Version() {
+		t.Error("Expected the version not to depend on the app settings")
+	}
+	if len(image.Version()) != 12 {
+		t.Errorf("Expected a version that fits into a label, got %q", image.Version())
+	}
+}
@@ -107,12 +107,23 @@ func PromptPushCommand(cliConnection plugin.CliConnection, args []string) {
 		fmt.Printf("Found droplet %s\n", dropletGUID)
 	}
 
+	previousDropletGUID, err := client.GetCurrentDropletGUID(appGUID)
+	if err != nil {
+		fmt.Printf("Warning: could not determine current droplet, rollback will not be possible: %v\n", err)
+	}
+
 	fmt.Println("Setting droplet as current...")
 	if err := client.SetCurrentDroplet(appGUID, dropletGUID); err != nil {
 		fmt.Printf("Error setting current droplet: %v\n", err)
 		os.Exit(1)
 	}
 
+	if previousDropletGUID != "" && previousDropletGUID != dropletGUID {
+		if err := recordPreviousDroplet(client, appGUID, previousDropletGUID); err != nil {
+			fmt.Printf("Warning: failed to record previous droplet for rollback: %v\n", err)
+		}
+	}
+
 	fmt.Println("OK")
 	fmt.Println()
 	fmt.Printf("Droplet %s has been set as current for app %s.\n", dropletGUID, appName)
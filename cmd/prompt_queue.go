@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// PromptQueueOptions holds the parsed arguments of the prompt-queue command
+type PromptQueueOptions struct {
+	App string
+	// Action is "list" or "remove", ID is the entry removed by "remove"
+	Action string
+	ID     string
+}
+
+// ParsePromptQueueArgs parses command line arguments for prompt-queue and returns the options and whether parsing failed
+func ParsePromptQueueArgs(args []string) (opts PromptQueueOptions, failed bool) {
+	if len(args) < 1 {
+		return PromptQueueOptions{}, true
+	}
+
+	opts.App = args[0]
+	opts.Action = "list"
+	if len(args) > 1 {
+		opts.Action = args[1]
+	}
+
+	switch {
+	case opts.Action == "list" && len(args) <= 2:
+	case opts.Action == "remove" && len(args) == 3:
+		opts.ID = args[2]
+	default:
+		return PromptQueueOptions{}, true
+	}
+	return opts, false
+}
+
+func PromptQueueCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptQueueArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-queue <APP_NAME> [list | remove ID]")
+		os.Exit(1)
+	}
+
+	appName := opts.App
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	appGUID, err := client.GetAppGUID(appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", appName, err)
+		os.Exit(1)
+	}
+
+	_, prompterGUID, err := findPrompter(client, appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	annotations, err := client.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		fmt.Printf("Error reading prompt queue: %v\n", err)
+		os.Exit(1)
+	}
+	queue := appQueue(runstate.Queue(annotations), appGUID)
+
+	if opts.Action == "remove" {
+		for _, entry := range queue {
+			if entry.ID != opts.ID {
+				continue
+			}
+			if err := client.UpdateAppAnnotations(prompterGUID, map[string]string{entry.Key(): ""}); err != nil {
+				fmt.Printf("Error removing queued prompt: %v\n", err)
+				os.Exit(1)
+			}
+			if entry.CredentialsService != "" {
+				if err := client.DeleteRunCredentials(entry.CredentialsService, currentSpace.Guid); err != nil {
+					fmt.Printf("Warning: failed to remove run credentials: %v\n", err)
+				}
+			}
+			fmt.Printf("Removed queued prompt %s\n", entry.ID)
+			return
+		}
+
+		fmt.Printf("Error: No queued prompt '%s' for app '%s'\n", opts.ID, appName)
+		os.Exit(1)
+	}
+
+	if len(queue) == 0 {
+		fmt.Printf("No queued prompts for app '%s'\n", appName)
+		return
+	}
+
+	table := newSimpleTable([]string{"id", "submitted", "by", "prompt"})
+	for _, entry := range queue {
+		prompt := entry.Prompt
+		if len(prompt) > 50 {
+			prompt = prompt[:47] + "..."
+		}
+		table.addRow(entry.ID, entry.SubmittedAt.Local().Format("2006-01-02 15:04:05"), entry.SubmittedBy, prompt)
+	}
+	table.print()
+
+	// The queue is only worked through by a running prompter, after a failed run the
+	// remaining prompts wait for the next successful one
+	if _, blocking := runstate.Blocking(annotations, time.Now()); !blocking {
+		fmt.Println()
+		fmt.Println("No prompt run is active, the queued prompts run after the next successful prompt run.")
+	}
+}
+
+// appQueue returns the queued prompts of an app. A shared prompter queues the prompts of
+// every app in the space. Entries without an app were queued by older versions of the plugin.
+func appQueue(queue []runstate.QueueEntry, appGUID string) []runstate.QueueEntry {
+	var entries []runstate.QueueEntry
+	for _, entry := range queue {
+		if entry.AppGUID == "" || entry.AppGUID == appGUID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
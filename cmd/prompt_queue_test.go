@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+func TestPromptQueueArgumentParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expected   PromptQueueOptions
+		shouldFail bool
+	}{
+		{
+			name:     "App only",
+			args:     []string{"test"},
+			expected: PromptQueueOptions{App: "test", Action: "list"},
+		},
+		{
+			name:     "List",
+			args:     []string{"test", "list"},
+			expected: PromptQueueOptions{App: "test", Action: "list"},
+		},
+		{
+			name:     "Remove",
+			args:     []string{"test", "remove", "3f9a2c1b7d4e"},
+			expected: PromptQueueOptions{App: "test", Action: "remove", ID: "3f9a2c1b7d4e"},
+		},
+		{
+			name:       "Remove without ID",
+			args:       []string{"test", "remove"},
+			shouldFail: true,
+		},
+		{
+			name:       "Unknown action",
+			args:       []string{"test", "clear"},
+			shouldFail: true,
+		},
+		{
+			name:       "No arguments",
+			args:       []string{},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptQueueArgs(tt.args)
+
+			if tt.shouldFail != failed {
+				t.Fatalf("Expected failed=%v, got %v", tt.shouldFail, failed)
+			}
+			if !tt.shouldFail && opts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestSharedPrompterQueue(t *testing.T) {
+	queue := []runstate.QueueEntry{
+		{ID: "a1", AppGUID: "app-1", Prompt: "rename endpoint"},
+		{ID: "b2", AppGUID: "app-2", Prompt: "add logging"},
+		{ID: "c3", Prompt: "bump timeout"},
+	}
+
+	entries := appQueue(queue, "app-1")
+	if len(entries) != 2 || entries[0].ID != "a1" || entries[1].ID != "c3" {
+		t.Errorf("Expected the entries of app-1 and the entry without an app, got %+v", entries)
+	}
+
+	state := runstate.New("3f9a2c1b7d4e", "add logging")
+	state.AppGUID = "app-2"
+	recorded, ok := runstate.FromAnnotations(state.Annotations())
+	if !ok || recorded.AppGUID != "app-2" {
+		t.Errorf("Expected the app of the run to be recorded, got %+v", recorded)
+	}
+}
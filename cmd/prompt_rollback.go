@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+)
+
+// maxDropletHistory bounds the number of previous droplets remembered for rollback
+const maxDropletHistory = 10
+
+// PromptRollbackOptions holds the parsed arguments of the prompt-rollback command
+type PromptRollbackOptions struct {
+	App     string
+	Steps   int
+	Restart bool
+}
+
+// ParsePromptRollbackArgs parses command line arguments for prompt-rollback and returns the options and whether parsing failed
+func ParsePromptRollbackArgs(args []string) (opts PromptRollbackOptions, failed bool) {
+	opts.Steps = 1
+	var nonFlagArgs []string
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--steps" && i+1 < len(args) {
+			steps, err := strconv.Atoi(args[i+1])
+			if err != nil || steps < 1 {
+				return PromptRollbackOptions{}, true
+			}
+			opts.Steps = steps
+			i++
+		} else if args[i] == "--restart" {
+			opts.Restart = true
+		} else {
+			nonFlagArgs = append(nonFlagArgs, args[i])
+		}
+	}
+
+	if len(nonFlagArgs) != 1 {
+		return PromptRollbackOptions{}, true
+	}
+	opts.App = nonFlagArgs[0]
+
+	return opts, false
+}
+
+// parseDropletHistory splits the droplet history annotation into droplet GUIDs, most recent first
+func parseDropletHistory(value string) []string {
+	var history []string
+	for _, guid := range strings.Split(value, ",") {
+		if guid = strings.TrimSpace(guid); guid != "" {
+			history = append(history, guid)
+		}
+	}
+	return history
+}
+
+// pushDropletHistory records dropletGUID as the most recent previous droplet
+func pushDropletHistory(history []string, dropletGUID string) []string {
+	updated := []string{dropletGUID}
+	for _, guid := range history {
+		if len(updated) == maxDropletHistory {
+			break
+		}
+		updated = append(updated, guid)
+	}
+	return updated
+}
+
+// recordPreviousDroplet remembers the droplet that is about to be replaced so it can be rolled back to
+func recordPreviousDroplet(client *cfclient.Client, appGUID, previousDropletGUID string) error {
+	value, _, err := client.GetAppAnnotation(appGUID, cfclient.AnnotationDropletHistory)
+	if err != nil {
+		return err
+	}
+
+	history := pushDropletHistory(parseDropletHistory(value), previousDropletGUID)
+	return client.UpdateAppAnnotations(appGUID, map[string]string{
+		cfclient.AnnotationDropletHistory: strings.Join(history, ","),
+	})
+}
+
+func PromptRollbackCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptRollbackArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-rollback <APP_NAME> [--steps N] [--restart]")
+		os.Exit(1)
+	}
+
+	appName := opts.App
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentOrg, err := cliConnection.GetCurrentOrg()
+	if err != nil {
+		fmt.Printf("Error getting current org: %v\n", err)
+		os.Exit(1)
+	}
+
+	username, err := cliConnection.Username()
+	if err != nil {
+		fmt.Printf("Error getting username: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	appGUID, err := client.GetAppGUID(appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", appName, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rolling back app %s in org %s / space %s as %s...\n\n", appName, currentOrg.Name, currentSpace.Name, username)
+
+	value, _, err := client.GetAppAnnotation(appGUID, cfclient.AnnotationDropletHistory)
+	if err != nil {
+		fmt.Printf("Error reading droplet history: %v\n", err)
+		os.Exit(1)
+	}
+
+	history := parseDropletHistory(value)
+	if len(history) < opts.Steps {
+		fmt.Printf("Error: cannot roll back %d step(s), only %d previous droplet(s) recorded for app '%s'\n", opts.Steps, len(history), appName)
+		os.Exit(1)
+	}
+
+	dropletGUID := history[opts.Steps-1]
+	if packageGUID, err := client.GetDropletPackageGUID(dropletGUID); err == nil {
+		fmt.Printf("Restoring droplet %s (package hash: %s)\n", dropletGUID, cfclient.ShortHash(packageGUID))
+	} else {
+		fmt.Printf("Restoring droplet %s\n", dropletGUID)
+	}
+
+	if err := client.SetCurrentDroplet(appGUID, dropletGUID); err != nil {
+		fmt.Printf("Error setting current droplet: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := client.UpdateAppAnnotations(appGUID, map[string]string{
+		cfclient.AnnotationDropletHistory: strings.Join(history[opts.Steps:], ","),
+	}); err != nil {
+		fmt.Printf("Warning: failed to update droplet history: %v\n", err)
+	}
+
+	if opts.Restart {
+		fmt.Printf("Restarting app %s...\n", appName)
+		if _, err := cliConnection.CliCommand("restart", appName); err != nil {
+			// Try manual restart as fallback
+			cmd := exec.Command("cf", "restart", appName)
+			if output, restartErr := cmd.CombinedOutput(); restartErr != nil {
+				fmt.Printf("Error restarting app: %v\nOutput: %s\n", restartErr, string(output))
+				os.Exit(1)
+			}
+		}
+	}
+
+	fmt.Println("OK")
+	fmt.Println()
+	fmt.Printf("Droplet %s has been restored as current for app %s.\n", dropletGUID, appName)
+	if !opts.Restart {
+		fmt.Printf("Run 'cf restart %s' to use the restored droplet.\n", appName)
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPromptRollbackArgumentParsing(t *testing.T) {
+	opts, failed := ParsePromptRollbackArgs([]string{"test"})
+	if failed || opts.App != "test" || opts.Steps != 1 || opts.Restart {
+		t.Errorf("Unexpected defaults: %+v (failed=%v)", opts, failed)
+	}
+
+	opts, failed = ParsePromptRollbackArgs([]string{"test", "--steps", "3", "--restart"})
+	if failed || opts.App != "test" || opts.Steps != 3 || !opts.Restart {
+		t.Errorf("Unexpected options: %+v (failed=%v)", opts, failed)
+	}
+
+	for _, args := range [][]string{{}, {"test", "--steps", "0"}, {"test", "--steps", "two"}} {
+		if _, failed := ParsePromptRollbackArgs(args); !failed {
+			t.Errorf("Expected parsing of %v to fail", args)
+		}
+	}
+}
+
+func TestDropletHistory(t *testing.T) {
+	history := parseDropletHistory("")
+	if len(history) != 0 {
+		t.Fatalf("Expected empty history, got %v", history)
+	}
+
+	history = pushDropletHistory(history, "first")
+	history = pushDropletHistory(history, "second")
+	if len(history) != 2 || history[0] != "second" || history[1] != "first" {
+		t.Fatalf("Expected most recent droplet first, got %v", history)
+	}
+
+	for i := 0; i < maxDropletHistory; i++ {
+		history = pushDropletHistory(history, fmt.Sprintf("droplet-%d", i))
+	}
+	if len(history) != maxDropletHistory {
+		t.Errorf("Expected history to be capped at %d, got %d", maxDropletHistory, len(history))
+	}
+
+	parsed := parseDropletHistory("a, b,,c")
+	if len(parsed) != 3 || parsed[1] != "b" {
+		t.Errorf("Unexpected parsed history: %v", parsed)
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+)
+
+// PromptShowOptions holds the parsed arguments of the prompt-show command
+type PromptShowOptions struct {
+	App   string
+	Hash  string
+	Files bool
+	Diff  bool
+}
+
+// ParsePromptShowArgs parses command line arguments for prompt-show and returns the options and whether parsing failed
+func ParsePromptShowArgs(args []string) (opts PromptShowOptions, failed bool) {
+	var nonFlagArgs []string
+
+	for _, arg := range args {
+		switch arg {
+		case "--files":
+			opts.Files = true
+		case "--diff":
+			opts.Diff = true
+		default:
+			nonFlagArgs = append(nonFlagArgs, arg)
+		}
+	}
+
+	if len(nonFlagArgs) != 2 {
+		return PromptShowOptions{}, true
+	}
+	opts.App = nonFlagArgs[0]
+	opts.Hash = nonFlagArgs[1]
+
+	return opts, false
+}
+
+func PromptShowCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptShowArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-show <APP_NAME> <PACKAGE_HASH> [--files] [--diff]")
+		os.Exit(1)
+	}
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+	client.SetRegistryCredentials(registryCredentials())
+
+	appGUID, err := client.GetAppGUID(opts.App, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", opts.App, err)
+		os.Exit(1)
+	}
+
+	pkg, err := client.FindPackageByShortHash(appGUID, opts.Hash)
+	if err != nil {
+		fmt.Printf("Error finding package: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentPackageGUID, err := client.GetCurrentDropletPackageGUID(appGUID)
+	if err != nil {
+		fmt.Printf("Error getting current droplet package: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("package %s\n", cfclient.ShortHash(pkg.GUID))
+
+	var details [][2]string
+	details = append(details, [2]string{"guid:", pkg.GUID})
+	details = append(details, [2]string{"state:", strings.ToLower(string(pkg.State))})
+	details = append(details, [2]string{"type:", pkg.Type})
+	details = append(details, [2]string{"created:", pkg.CreatedAt.Format("2006-01-02 15:04:05")})
+	if pkg.GUID == currentPackageGUID {
+		details = append(details, [2]string{"current:", "yes"})
+	} else {
+		details = append(details, [2]string{"current:", "no"})
+	}
+
+	var parent *resource.Package
+	if parentGUID, ok := client.GetParentPackageGUID(pkg); ok {
+		parent, err = client.GetPackage(parentGUID)
+		if err != nil {
+			details = append(details, [2]string{"parent:", fmt.Sprintf("%s (no longer available)", cfclient.ShortHash(parentGUID))})
+		} else {
+			details = append(details, [2]string{"parent:", cfclient.ShortHash(parentGUID)})
+		}
+	}
+
+	if dropletGUID, err := client.GetPackageDropletGUID(pkg.GUID); err == nil {
+		dropletStatus, _ := client.GetPackageDropletStatus(pkg.GUID)
+		details = append(details, [2]string{"droplet:", fmt.Sprintf("%s (%s)", dropletGUID, dropletStatus)})
+	} else {
+		details = append(details, [2]string{"droplet:", "none"})
+	}
+
+	if build, err := client.GetPackageBuild(pkg.GUID); err == nil && build != nil {
+		details = append(details, [2]string{"build:", fmt.Sprintf("%s (%s)", build.GUID, strings.ToLower(string(build.State)))})
+	} else {
+		details = append(details, [2]string{"build:", "none"})
+	}
+
+	for _, detail := range details {
+		fmt.Printf("%-10s %s\n", detail[0], detail[1])
+	}
+
+	fmt.Println()
+	if prompt, ok := client.GetOriginalPrompt(pkg); ok {
+		for _, line := range strings.Split(prompt, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	} else {
+		fmt.Println("    (no prompt stored)")
+	}
+	fmt.Println()
+
+	// The contents are only downloaded when a flag needs them
+	if !opts.Files && !opts.Diff {
+		return
+	}
+
+	files, err := loadPackageFiles(client, pkg)
+	if err != nil {
+		fmt.Printf("Error loading package contents: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.Files {
+		fmt.Printf(" %d %s\n", len(files), pluralize(len(files), "file", "files"))
+
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+
+		fmt.Println()
+		for _, line := range renderFileTree(paths) {
+			fmt.Println(line)
+		}
+	}
+
+	if opts.Diff {
+		if opts.Files {
+			fmt.Println()
+		}
+		if parent == nil {
+			fmt.Println("No parent package recorded, nothing to diff against")
+			return
+		}
+
+		parentFiles, err := loadPackageFiles(client, parent)
+		if err != nil {
+			fmt.Printf("Error loading parent package contents: %v\n", err)
+			os.Exit(1)
+		}
+
+		changes := diffTrees(parentFiles, files)
+		if len(changes) == 0 {
+			fmt.Println(" no changes compared to parent")
+			return
+		}
+		writeDiffStat(os.Stdout, changes)
+		fmt.Println()
+		writeUnifiedDiff(os.Stdout, changes)
+	}
+}
+
+// renderFileTree lays out slash separated file paths as a directory tree
+func renderFileTree(paths []string) []string {
+	type dir struct {
+		dirs  map[string]*dir
+		files []string
+	}
+	newDir := func() *dir {
+		return &dir{dirs: make(map[string]*dir)}
+	}
+
+	root := newDir()
+	for _, path := range paths {
+		parts := strings.Split(path, "/")
+		current := root
+		for _, part := range parts[:len(parts)-1] {
+			if current.dirs[part] == nil {
+				current.dirs[part] = newDir()
+			}
+			current = current.dirs[part]
+		}
+		current.files = append(current.files, parts[len(parts)-1])
+	}
+
+	lines := []string{"."}
+
+	var walk func(d *dir, prefix string)
+	walk = func(d *dir, prefix string) {
+		type entry struct {
+			name string
+			dir  *dir
+		}
+
+		var entries []entry
+		for name, sub := range d.dirs {
+			entries = append(entries, entry{name: name, dir: sub})
+		}
+		for _, name := range d.files {
+			entries = append(entries, entry{name: name})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].name < entries[j].name
+		})
+
+		for i, e := range entries {
+			connector, childPrefix := "├── ", "│   "
+			if i == len(entries)-1 {
+				connector, childPrefix = "└── ", "    "
+			}
+
+			if e.dir != nil {
+				lines = append(lines, prefix+connector+e.name+"/")
+				walk(e.dir, prefix+childPrefix)
+			} else {
+				lines = append(lines, prefix+connector+e.name)
+			}
+		}
+	}
+	walk(root, "")
+
+	return lines
+}
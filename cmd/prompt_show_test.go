@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptShowArgumentParsing(t *testing.T) {
+	opts, failed := ParsePromptShowArgs([]string{"test", "a1b2c3d", "--files", "--diff"})
+	if failed {
+		t.Fatal("Expected parsing to succeed, but it failed")
+	}
+	expected := PromptShowOptions{App: "test", Hash: "a1b2c3d", Files: true, Diff: true}
+	if opts != expected {
+		t.Errorf("Expected %+v, got %+v", expected, opts)
+	}
+
+	if _, failed := ParsePromptShowArgs([]string{"test", "--files"}); !failed {
+		t.Error("Expected parsing to fail without a package hash")
+	}
+}
+
+func TestRenderFileTree(t *testing.T) {
+	lines := renderFileTree([]string{"main.go", "static/css/site.css", "go.mod", "static/index.html"})
+
+	expected := []string{
+		".",
+		"├── go.mod",
+		"├── main.go",
+		"└── static/",
+		"    ├── css/",
+		"    │   └── site.css",
+		"    └── index.html",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected tree:\n%s\nexpected:\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
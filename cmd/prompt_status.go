@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/prompter"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// PromptStatusOptions holds the parsed arguments of the prompt-status command
+type PromptStatusOptions struct {
+	App   string
+	RunID string
+}
+
+// ParsePromptStatusArgs parses command line arguments for prompt-status and returns the options and whether parsing failed
+func ParsePromptStatusArgs(args []string) (opts PromptStatusOptions, failed bool) {
+	if len(args) < 1 || len(args) > 2 {
+		return PromptStatusOptions{}, true
+	}
+
+	opts.App = args[0]
+	if len(args) == 2 {
+		opts.RunID = args[1]
+	}
+	return opts, false
+}
+
+func PromptStatusCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptStatusArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-status <APP_NAME> [RUN_ID]")
+		os.Exit(1)
+	}
+
+	appName := opts.App
+
+	apiEndpoint, err := cliConnection.ApiEndpoint()
+	if err != nil {
+		fmt.Printf("Error getting API endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := cliConnection.AccessToken()
+	if err != nil {
+		fmt.Printf("Error getting access token: %v\n", err)
+		os.Exit(1)
+	}
+
+	currentSpace, err := cliConnection.GetCurrentSpace()
+	if err != nil {
+		fmt.Printf("Error getting current space: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		fmt.Printf("Error creating CF client: %v\n", err)
+		os.Exit(1)
+	}
+
+	appGUID, err := client.GetAppGUID(appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error getting app GUID for '%s': %v\n", appName, err)
+		os.Exit(1)
+	}
+
+	_, prompterGUID, err := findPrompter(client, appName, currentSpace.Guid)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	state, interrupted, err := loadRunState(client, prompterGUID, appGUID)
+	if err != nil {
+		fmt.Printf("Error reading run state: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.RunID != "" && (state == nil || state.RunID != opts.RunID) {
+		// Only the latest run is recorded on the prompter, earlier successful runs
+		// can still be found through the package they created
+		pkg, err := findPackageByRunID(client, appGUID, opts.RunID)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		state = &runstate.State{
+			RunID:       opts.RunID,
+			Status:      runstate.StatusSucceeded,
+			Phase:       events.PhaseUpload,
+			UpdatedAt:   pkg.CreatedAt,
+			PackageGUID: pkg.GUID,
+		}
+		state.Prompt, _ = client.GetOriginalPrompt(pkg)
+		interrupted = false
+	}
+
+	if state == nil {
+		fmt.Printf("No prompt runs recorded for app '%s'\n", appName)
+		return
+	}
+
+	writeRunState(os.Stdout, appName, *state, interrupted, time.Now())
+}
+
+// loadRunState returns the state of the latest run of the app recorded on the prompter
+// app, or nil if there is none. A shared prompter may have recorded a run of another
+// app. interrupted is set if the run did not end but the prompter is stopped, or for
+// task runs, its task has ended.
+func loadRunState(client *cfclient.Client, prompterGUID, appGUID string) (state *runstate.State, interrupted bool, err error) {
+	annotations, err := client.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	recorded, ok := runstate.FromAnnotations(annotations)
+	if !ok || (recorded.AppGUID != "" && recorded.AppGUID != appGUID) {
+		return nil, false, nil
+	}
+
+	if !recorded.Finished() {
+		task, isTask, err := client.FindTask(prompterGUID, prompter.TaskName(recorded.RunID))
+		if err != nil {
+			return nil, false, err
+		}
+		if isTask {
+			return &recorded, cfclient.TaskFinished(task.State), nil
+		}
+
+		app, err := client.GetApp(prompterGUID)
+		if err != nil {
+			return nil, false, err
+		}
+		interrupted = app.State == "STOPPED"
+	}
+
+	return &recorded, interrupted, nil
+}
+
+func findPackageByRunID(client *cfclient.Client, appGUID, runID string) (*resource.Package, error) {
+	packages, err := client.ListPackagesWithPrompts(appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range packages {
+		if value, ok := cfclient.GetAnnotation(pkg, cfclient.AnnotationRunID); ok && value == runID {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("prompt run '%s' not found", runID)
+}
+
+// writeRunState prints the state of a run
+func writeRunState(w io.Writer, app string, state runstate.State, interrupted bool, now time.Time) {
+	status := state.Status
+	if interrupted {
+		status = "interrupted (the prompter stopped before the run ended)"
+	}
+
+	var details [][2]string
+	details = append(details, [2]string{"run:", state.RunID})
+	details = append(details, [2]string{"status:", status})
+	details = append(details, [2]string{"phase:", state.Phase})
+	if !state.StartedAt.IsZero() {
+		started := state.StartedAt.Local().Format("2006-01-02 15:04:05")
+		if !state.Finished() && !interrupted {
+			started = fmt.Sprintf("%s (%s ago)", started, now.Sub(state.StartedAt).Round(time.Second))
+		}
+		details = append(details, [2]string{"started:", started})
+	}
+	if !state.UpdatedAt.IsZero() {
+		details = append(details, [2]string{"updated:", state.UpdatedAt.Local().Format("2006-01-02 15:04:05")})
+	}
+	if state.PackageGUID != "" {
+		details = append(details, [2]string{"package:", cfclient.ShortHash(state.PackageGUID)})
+	}
+	if state.Error != "" {
+		details = append(details, [2]string{"error:", state.Error})
+	}
+	if state.Prompt != "" {
+		details = append(details, [2]string{"prompt:", state.Prompt})
+	}
+
+	for _, detail := range details {
+		fmt.Fprintf(w, "%-10s %s\n", detail[0], detail[1])
+	}
+
+	switch {
+	case state.Status == runstate.StatusSucceeded && state.PackageGUID != "":
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Run 'cf prompt-push %s %s' to deploy the new package.\n", app, cfclient.ShortHash(state.PackageGUID))
+	case !state.Finished() && !interrupted:
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Run 'cf prompt-attach %s' to follow its logs.\n", app)
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+func TestPromptStatusArgumentParsing(t *testing.T) {
+	opts, failed := ParsePromptStatusArgs([]string{"test"})
+	if failed || opts.App != "test" || opts.RunID != "" {
+		t.Errorf("Unexpected result for app only: %+v, failed=%v", opts, failed)
+	}
+
+	opts, failed = ParsePromptStatusArgs([]string{"test", "3f9a2c1b7d4e"})
+	if failed || opts.App != "test" || opts.RunID != "3f9a2c1b7d4e" {
+		t.Errorf("Unexpected result for app and run: %+v, failed=%v", opts, failed)
+	}
+
+	for _, args := range [][]string{{}, {"test", "run", "extra"}} {
+		if _, failed := ParsePromptStatusArgs(args); !failed {
+			t.Errorf("Expected parsing of %v to fail", args)
+		}
+	}
+}
+
+func TestWriteRunState(t *testing.T) {
+	started := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := runstate.State{
+		RunID:     "3f9a2c1b7d4e",
+		Status:    runstate.StatusRunning,
+		Phase:     events.PhaseAgent,
+		StartedAt: started,
+		UpdatedAt: started,
+	}
+
+	var buf bytes.Buffer
+	writeRunState(&buf, "test", state, false, started.Add(90*time.Second))
+	output := buf.String()
+	for _, fragment := range []string{"run:       3f9a2c1b7d4e", "status:    running", "phase:     agent", "(1m30s ago)", "cf prompt-attach test"} {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("Output should contain %q, got:\n%s", fragment, output)
+		}
+	}
+
+	buf.Reset()
+	writeRunState(&buf, "test", state, true, started.Add(time.Hour))
+	if !strings.Contains(buf.String(), "status:    interrupted") || strings.Contains(buf.String(), "prompt-attach") {
+		t.Errorf("Unexpected output for interrupted run:\n%s", buf.String())
+	}
+}
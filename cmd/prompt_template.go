@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/templates"
+)
+
+// PromptTemplateOptions holds the parsed arguments of the prompt-template command
+type PromptTemplateOptions struct {
+	// Action is "list", "show", "save" or "delete"
+	Action string
+	Name   string
+	// Prompt and File hold the template text for "save", "-" reads it from stdin
+	Prompt string
+	File   string
+}
+
+// ParsePromptTemplateArgs parses command line arguments for prompt-template and returns the options and whether parsing failed
+func ParsePromptTemplateArgs(args []string) (opts PromptTemplateOptions, failed bool) {
+	var nonFlagArgs []string
+
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "--prompt" || args[i] == "-p") && i+1 < len(args) {
+			opts.Prompt = args[i+1]
+			i++
+		} else if (args[i] == "--file" || args[i] == "-f") && i+1 < len(args) {
+			opts.File = args[i+1]
+			i++
+		} else {
+			nonFlagArgs = append(nonFlagArgs, args[i])
+		}
+	}
+
+	if len(nonFlagArgs) == 0 {
+		return PromptTemplateOptions{}, true
+	}
+	opts.Action = nonFlagArgs[0]
+
+	switch opts.Action {
+	case "list":
+		if len(nonFlagArgs) != 1 || opts.Prompt != "" || opts.File != "" {
+			return PromptTemplateOptions{}, true
+		}
+	case "show", "delete":
+		if len(nonFlagArgs) != 2 || opts.Prompt != "" || opts.File != "" {
+			return PromptTemplateOptions{}, true
+		}
+		opts.Name = nonFlagArgs[1]
+	case "save":
+		if len(nonFlagArgs) != 2 || (opts.Prompt == "") == (opts.File == "") {
+			return PromptTemplateOptions{}, true
+		}
+		opts.Name = nonFlagArgs[1]
+	default:
+		return PromptTemplateOptions{}, true
+	}
+
+	return opts, false
+}
+
+func PromptTemplateCommand(cliConnection plugin.CliConnection, args []string) {
+	opts, failed := ParsePromptTemplateArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompt-template list")
+		fmt.Println("       cf prompt-template show NAME")
+		fmt.Println("       cf prompt-template save NAME -p 'prompt text' | -f FILE")
+		fmt.Println("       cf prompt-template delete NAME")
+		os.Exit(1)
+	}
+
+	store, err := templateStore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch opts.Action {
+	case "list":
+		list, err := store.List()
+		if err != nil {
+			fmt.Printf("Error listing templates: %v\n", err)
+			os.Exit(1)
+		}
+		if len(list) == 0 {
+			fmt.Println("No prompt templates saved")
+			return
+		}
+
+		table := newSimpleTable([]string{"name", "variables", "updated", "template"})
+		for _, template := range list {
+			prompt := strings.Join(strings.Fields(template.Prompt), " ")
+			if len(prompt) > 50 {
+				prompt = prompt[:47] + "..."
+			}
+			table.addRow(template.Name, strings.Join(template.Variables(), ", "), template.UpdatedAt.Local().Format("2006-01-02 15:04:05"), prompt)
+		}
+		table.print()
+
+	case "show":
+		template, err := store.Get(opts.Name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-10s %s\n", "name:", template.Name)
+		if variables := template.Variables(); len(variables) > 0 {
+			fmt.Printf("%-10s %s\n", "variables:", strings.Join(variables, ", "))
+		}
+		fmt.Printf("%-10s %s\n", "updated:", template.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+		fmt.Println()
+		fmt.Println(template.Prompt)
+
+	case "save":
+		prompt := PromptOptions{Prompt: opts.Prompt, File: opts.File}
+		if err := loadPrompt(&prompt, os.Stdin); err != nil {
+			fmt.Printf("Error reading template: %v\n", err)
+			os.Exit(1)
+		}
+		if err := store.Save(opts.Name, prompt.Prompt); err != nil {
+			fmt.Printf("Error saving template: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved prompt template '%s'\n", opts.Name)
+
+	case "delete":
+		if err := store.Delete(opts.Name); err != nil {
+			fmt.Printf("Error deleting template: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted prompt template '%s'\n", opts.Name)
+	}
+}
+
+func templateStore() (*templates.Store, error) {
+	path, err := templates.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return templates.NewStore(path), nil
+}
+
+// renderTemplate replaces the prompt with the rendered template if one was selected
+func renderTemplate(opts *PromptOptions) error {
+	if opts.Template == "" {
+		return nil
+	}
+
+	store, err := templateStore()
+	if err != nil {
+		return err
+	}
+	template, err := store.Get(opts.Template)
+	if err != nil {
+		return err
+	}
+
+	prompt, err := template.Render(opts.Vars)
+	if err != nil {
+		return err
+	}
+	opts.Prompt = prompt
+	return nil
+}
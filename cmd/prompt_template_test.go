@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestPromptTemplateArgumentParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expected   PromptTemplateOptions
+		shouldFail bool
+	}{
+		{
+			name:     "List",
+			args:     []string{"list"},
+			expected: PromptTemplateOptions{Action: "list"},
+		},
+		{
+			name:     "Show",
+			args:     []string{"show", "health"},
+			expected: PromptTemplateOptions{Action: "show", Name: "health"},
+		},
+		{
+			name:     "Save prompt text",
+			args:     []string{"save", "health", "-p", "add a /health endpoint on path {{path}}"},
+			expected: PromptTemplateOptions{Action: "save", Name: "health", Prompt: "add a /health endpoint on path {{path}}"},
+		},
+		{
+			name:     "Save prompt file",
+			args:     []string{"save", "upgrade", "--file", "upgrade.md"},
+			expected: PromptTemplateOptions{Action: "save", Name: "upgrade", File: "upgrade.md"},
+		},
+		{
+			name:     "Delete",
+			args:     []string{"delete", "health"},
+			expected: PromptTemplateOptions{Action: "delete", Name: "health"},
+		},
+		{
+			name:       "Save without prompt",
+			args:       []string{"save", "health"},
+			shouldFail: true,
+		},
+		{
+			name:       "Show without name",
+			args:       []string{"show"},
+			shouldFail: true,
+		},
+		{
+			name:       "Unknown action",
+			args:       []string{"rename", "health", "status"},
+			shouldFail: true,
+		},
+		{
+			name:       "No arguments",
+			args:       []string{},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptTemplateArgs(tt.args)
+
+			if tt.shouldFail != failed {
+				t.Fatalf("Expected failed=%v, got %v", tt.shouldFail, failed)
+			}
+			if !tt.shouldFail && opts != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
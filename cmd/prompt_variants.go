@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// runVariants runs the prompt several times from the same base package, so that the
+// resulting packages can be compared and the best one deployed. The runs share the
+// prompter of the app and are therefore run one after another.
+func runVariants(cliConnection plugin.CliConnection, opts PromptOptions) {
+	printPromptHeader(opts)
+
+	target := newPromptTarget(cliConnection)
+
+	// Without an explicit base every variant would start from the previous one
+	if opts.basePackage() == "" {
+		appGUID, err := target.client.GetAppGUID(opts.App, target.spaceGUID)
+		if err != nil {
+			fmt.Printf("Error getting app GUID: %v\n", err)
+			os.Exit(1)
+		}
+		latest, err := target.client.GetLatestPackage(appGUID)
+		if err != nil {
+			fmt.Printf("Error getting latest package: %v\n", err)
+			os.Exit(1)
+		}
+		opts.From = cfclient.ShortHash(latest.GUID)
+	}
+
+	group, err := runstate.NewRunID()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Creating %d variants in group %s\n", opts.Variants, group)
+
+	table := newSimpleTable([]string{"variant", "status", "package"})
+	var created []string
+	for i := 1; i <= opts.Variants; i++ {
+		fmt.Printf("\n[variant %d/%d]\n", i, opts.Variants)
+
+		run := opts
+		run.VariantGroup = group
+		run.VariantIndex = i
+		succeeded, packageHash := executeRun(cliConnection, target, run)
+
+		status := "succeeded"
+		if !succeeded {
+			status = "failed"
+		} else if packageHash != "" {
+			created = append(created, packageHash)
+		}
+		table.addRow(strconv.Itoa(i), status, packageHash)
+	}
+
+	fmt.Println()
+	fmt.Printf("Variants of group %s:\n", group)
+	table.print()
+
+	if len(created) == 0 {
+		fmt.Println()
+		fmt.Println("Error: no variant was created")
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Printf("Use 'cf prompt-diff %s --variants %s' to compare them and 'cf prompt-push %s %s' to deploy one.\n", opts.App, group, opts.App, created[0])
+}
+
+// variantOf returns the variant group and index of a package, group is empty for
+// packages that are not a variant
+func variantOf(pkg *resource.Package) (group string, index int) {
+	group, ok := cfclient.GetAnnotation(pkg, cfclient.AnnotationVariantGroup)
+	if !ok {
+		return "", 0
+	}
+	value, _ := cfclient.GetAnnotation(pkg, cfclient.AnnotationVariantIndex)
+	index, _ = strconv.Atoi(value)
+	return group, index
+}
+
+// variantLabel returns the group and index of a variant package for listings, or an
+// empty string for other packages
+func variantLabel(pkg *resource.Package) string {
+	group, index := variantOf(pkg)
+	if group == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", group, index)
+}
+
+// groupVariants reorders packages so the variants of a group follow each other in
+// index order, at the position of the group's first package. Other packages keep their order.
+func groupVariants(packages []*resource.Package) []*resource.Package {
+	members := make(map[string][]*resource.Package)
+	for _, pkg := range packages {
+		if group, _ := variantOf(pkg); group != "" {
+			members[group] = append(members[group], pkg)
+		}
+	}
+
+	grouped := make([]*resource.Package, 0, len(packages))
+	for _, pkg := range packages {
+		group, _ := variantOf(pkg)
+		if group == "" {
+			grouped = append(grouped, pkg)
+			continue
+		}
+		if members[group] == nil {
+			continue
+		}
+
+		variants := members[group]
+		sort.SliceStable(variants, func(i, j int) bool {
+			_, a := variantOf(variants[i])
+			_, b := variantOf(variants[j])
+			return a < b
+		})
+		grouped = append(grouped, variants...)
+		members[group] = nil
+	}
+	return grouped
+}
+
+// findVariants returns the packages of a variant group in index order
+func findVariants(packages []*resource.Package, group string) []*resource.Package {
+	var variants []*resource.Package
+	for _, pkg := range groupVariants(packages) {
+		if g, _ := variantOf(pkg); g == group {
+			variants = append(variants, pkg)
+		}
+	}
+	return variants
+}
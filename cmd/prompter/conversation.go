@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+)
+
+// maxConversationDepth bounds how many earlier prompts are replayed as context
+const maxConversationDepth = 10
+
+// conversationHistory returns the prompts that produced pkg, oldest first. It follows
+// parent packages for as long as each package continued the conversation of its parent.
+func conversationHistory(client *cfclient.Client, pkg *resource.Package) ([]string, error) {
+	var history []string
+
+	current := pkg
+	for len(history) < maxConversationDepth {
+		prompt, ok := client.GetOriginalPrompt(current)
+		if !ok {
+			break
+		}
+		history = append([]string{prompt}, history...)
+
+		if _, continued := cfclient.GetAnnotation(current, cfclient.AnnotationContinues); !continued {
+			break
+		}
+
+		parentGUID, ok := client.GetParentPackageGUID(current)
+		if !ok {
+			break
+		}
+
+		parent, err := client.GetPackage(parentGUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent package %s: %w", parentGUID, err)
+		}
+		current = parent
+	}
+
+	return history, nil
+}
+
+// buildConversationPrompt prefixes a follow-up prompt with the earlier prompts of the
+// conversation so the agent knows what was already asked and applied
+func buildConversationPrompt(history []string, prompt string) string {
+	if len(history) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString("This is a follow-up request. The following earlier instructions were already applied to the code in this directory, in order:\n\n")
+	for i, earlier := range history {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, earlier)
+	}
+	b.WriteString("\nNow apply the following instruction, building on the changes made so far:\n\n")
+	b.WriteString(prompt)
+
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+)
+
+// credentialsServiceTag marks user-provided services whose credentials are passed to the agent
+const credentialsServiceTag = "cf-prompt"
+
+// providerSettingPattern matches the credentials that may be exported to the agent: API
+// keys and endpoints of providers. Anything else, such as PATH or CF_ACCESS_TOKEN, would
+// let a bound service change how the prompter and the validation command run.
+var providerSettingPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*_(API_KEY|BASE_URL)$`)
+
+// providerVariables are provider settings that do not follow the naming of providerSettingPattern
+var providerVariables = map[string]bool{
+	"AWS_ACCESS_KEY_ID":        true,
+	"AWS_SECRET_ACCESS_KEY":    true,
+	"AWS_SESSION_TOKEN":        true,
+	"AWS_REGION":               true,
+	"AWS_BEARER_TOKEN_BEDROCK": true,
+	"AZURE_RESOURCE_NAME":      true,
+	"GOOGLE_CLOUD_PROJECT":     true,
+	"VERTEX_LOCATION":          true,
+}
+
+func isProviderSetting(key string) bool {
+	return providerSettingPattern.MatchString(key) || providerVariables[key]
+}
+
+type boundService struct {
+	Name        string                 `json:"name"`
+	Tags        []string               `json:"tags"`
+	Credentials map[string]interface{} `json:"credentials"`
+}
+
+// providerCredentials returns the credentials of the user-provided services bound to the
+// prompter that are tagged with credentialsServiceTag. Only provider settings, such as
+// ANTHROPIC_API_KEY, with string values are returned, the keys of all other credentials
+// are returned as rejected.
+func providerCredentials(vcapServices string) (credentials map[string]string, rejected []string, err error) {
+	credentials = make(map[string]string)
+	if vcapServices == "" {
+		return credentials, nil, nil
+	}
+
+	var services map[string][]boundService
+	if err := json.Unmarshal([]byte(vcapServices), &services); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse VCAP_SERVICES: %w", err)
+	}
+
+	for _, service := range services["user-provided"] {
+		if !hasTag(service.Tags, credentialsServiceTag) {
+			continue
+		}
+		for key, value := range service.Credentials {
+			str, ok := value.(string)
+			if !ok || !isProviderSetting(key) {
+				rejected = append(rejected, key)
+				continue
+			}
+			credentials[key] = str
+		}
+	}
+
+	sort.Strings(rejected)
+	return credentials, rejected, nil
+}
+
+// runCredentials returns the secrets handed over by 'cf prompt' for the given run and the
+// name of the service instance holding them. ok is false if no such service is bound.
+func runCredentials(vcapServices, runID string) (credentials map[string]string, serviceName string, ok bool, err error) {
+	if vcapServices == "" || runID == "" {
+		return nil, "", false, nil
+	}
+
+	var services map[string][]boundService
+	if err := json.Unmarshal([]byte(vcapServices), &services); err != nil {
+		return nil, "", false, fmt.Errorf("failed to parse VCAP_SERVICES: %w", err)
+	}
+
+	for _, service := range services["user-provided"] {
+		if !hasTag(service.Tags, cfclient.RunCredentialsTag) || service.Credentials["run_id"] != runID {
+			continue
+		}
+
+		credentials = make(map[string]string)
+		for key, value := range service.Credentials {
+			if str, ok := value.(string); ok {
+				credentials[key] = str
+			}
+		}
+		return credentials, service.Name, true, nil
+	}
+
+	return nil, "", false, nil
+}
+
+// exportProviderCredentials makes the bound provider credentials available to the agent
+// process through the environment
+func exportProviderCredentials() error {
+	credentials, rejected, err := providerCredentials(os.Getenv("VCAP_SERVICES"))
+	if err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		fmt.Printf("Warning: ignoring credentials that are not provider settings: %v\n", rejected)
+	}
+
+	keys := make([]string, 0, len(credentials))
+	for key, value := range credentials {
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		fmt.Printf("Using provider credentials from bound services: %v\n", keys)
+	}
+	return nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProviderCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		vcapServices string
+		expected     map[string]string
+		rejected     []string
+		shouldFail   bool
+	}{
+		{
+			name:     "No services",
+			expected: map[string]string{},
+		},
+		{
+			name: "Tagged service",
+			vcapServices: `{"user-provided": [{"name": "llm", "tags": ["cf-prompt"], "credentials": {
+				"ANTHROPIC_API_KEY": "sk-1", "OPENAI_BASE_URL": "https://llm.example.com", "AWS_REGION": "eu-central-1"}}]}`,
+			expected: map[string]string{
+				"ANTHROPIC_API_KEY": "sk-1",
+				"OPENAI_BASE_URL":   "https://llm.example.com",
+				"AWS_REGION":        "eu-central-1",
+			},
+		},
+		{
+			name: "Untagged service",
+			vcapServices: `{"user-provided": [{"name": "other", "tags": [], "credentials": {
+				"ANTHROPIC_API_KEY": "sk-1"}}]}`,
+			expected: map[string]string{},
+		},
+		{
+			name: "Variables that are not provider settings",
+			vcapServices: `{"user-provided": [{"name": "llm", "tags": ["cf-prompt"], "credentials": {
+				"ANTHROPIC_API_KEY": "sk-1", "PATH": "/tmp/evil", "LD_PRELOAD": "/tmp/evil.so",
+				"CF_ACCESS_TOKEN": "token", "REGISTRY_PASSWORD": "secret", "ports": 8080}}]}`,
+			expected: map[string]string{"ANTHROPIC_API_KEY": "sk-1"},
+			rejected: []string{"CF_ACCESS_TOKEN", "LD_PRELOAD", "PATH", "REGISTRY_PASSWORD", "ports"},
+		},
+		{
+			name:         "Invalid VCAP_SERVICES",
+			vcapServices: `{`,
+			shouldFail:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentials, rejected, err := providerCredentials(tt.vcapServices)
+
+			if tt.shouldFail != (err != nil) {
+				t.Fatalf("Expected failure=%v, got %v", tt.shouldFail, err)
+			}
+			if tt.shouldFail {
+				return
+			}
+			if !reflect.DeepEqual(credentials, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, credentials)
+			}
+			if !reflect.DeepEqual(rejected, tt.rejected) {
+				t.Errorf("Expected rejected %v, got %v", tt.rejected, rejected)
+			}
+		})
+	}
+}
+
+func TestRunCredentials(t *testing.T) {
+	vcapServices := `{"user-provided": [
+		{"name": "app-prompter-run-1", "tags": ["cf-prompt-run"], "credentials": {"run_id": "1", "CF_ACCESS_TOKEN": "token-1"}},
+		{"name": "app-prompter-run-2", "tags": ["cf-prompt-run"], "credentials": {"run_id": "2", "CF_ACCESS_TOKEN": "token-2"}}
+	]}`
+
+	credentials, serviceName, ok, err := runCredentials(vcapServices, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || serviceName != "app-prompter-run-2" || credentials["CF_ACCESS_TOKEN"] != "token-2" {
+		t.Errorf("Expected the credentials of run 2, got %v from %q (ok=%v)", credentials, serviceName, ok)
+	}
+
+	if _, _, ok, err := runCredentials(vcapServices, "3"); err != nil || ok {
+		t.Errorf("Expected no credentials for run 3, got ok=%v, err=%v", ok, err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/opencode"
+)
+
+// runDoctor checks, from inside the prompter, what a run needs: the CF API at CF_API,
+// the download of OpenCode and provider credentials. The results are reported as check
+// events for 'cf prompt-doctor', the exit status tells whether all checks passed.
+func runDoctor() {
+	emitter := events.NewEmitter(os.Stdout, "")
+	passed := true
+
+	report := func(check string, err error, message string) {
+		event := events.Event{Type: events.TypeCheck, Check: check, Passed: err == nil, Message: message}
+		if err != nil {
+			event.Message = err.Error()
+			passed = false
+		}
+		emitter.Emit(event)
+	}
+
+	api := os.Getenv("CF_API")
+	report(events.CheckCFAPI, checkAPI(api), api)
+
+	url, err := opencode.DownloadURL()
+	if err == nil {
+		err = checkDownload(url)
+	}
+	report(events.CheckOpencodeDownload, err, url)
+
+	keys, err := credentialKeys(os.Getenv("VCAP_SERVICES"), os.Environ())
+	if err == nil && len(keys) == 0 {
+		err = fmt.Errorf("no provider credentials found")
+	}
+	report(events.CheckProviderCredentials, err, strings.Join(keys, ", "))
+
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// checkAPI makes sure the CF API answers at the endpoint runs use. The root endpoint
+// needs no token, TLS is not verified, just like the client of a run.
+func checkAPI(api string) error {
+	if api == "" {
+		return fmt.Errorf("CF_API is not set")
+	}
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := httpClient.Get(strings.TrimSuffix(api, "/") + "/")
+	if err != nil {
+		return fmt.Errorf("failed to reach CF API at %s: %w", api, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to reach CF API at %s: status %d", api, resp.StatusCode)
+	}
+	return nil
+}
+
+// checkDownload makes sure the release can be downloaded without fetching all of it
+func checkDownload(url string) error {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := httpClient.Head(url)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// credentialKeys returns the names of the provider credentials available to the agent:
+// those of bound services tagged credentialsServiceTag and API keys set in the environment
+func credentialKeys(vcapServices string, environ []string) ([]string, error) {
+	credentials, _, err := providerCredentials(vcapServices)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(credentials))
+	for key := range credentials {
+		found[key] = true
+	}
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		if strings.HasSuffix(key, "_API_KEY") && value != "" {
+			found[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
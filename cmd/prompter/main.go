@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/agent"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
-	"github.com/ruben/cf-prompt-cli-plugin/pkg/opencode"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/registry"
 )
 
@@ -21,30 +24,113 @@ type Config struct {
 	RegistryUsername string
 	RegistryPassword string
 	Prompt           string
+	BasePackage      string
+	Continue         bool
+	ValidateCommand  string
+	ValidateAttempts string
+	Agent            string
+	AgentCommand     string
+	Model            string
+	Provider         string
+	RunID            string
+	VariantGroup     string
+	VariantIndex     string
+	Template         string
+	TemplateVars     string
+	// CredentialsService is the service instance the secrets were read from, if any
+	CredentialsService string
+	// Task is set when the prompter runs as a CF task, which ends with the process
+	// instead of stopping the prompter app
+	Task bool
 }
 
 func main() {
-	if err := opencode.EnsureInstalled(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error installing opencode: %v\n", err)
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor()
+		return
 	}
 
 	config, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-		os.Exit(1)
+		fail("Error loading configuration: %v", err)
+	}
+
+	if err := exportProviderCredentials(); err != nil {
+		fail("Error loading provider credentials: %v", err)
+	}
+
+	fmt.Println("Creating CF client...")
+	client, err := cfclient.New(config.API, config.AccessToken)
+	if err != nil {
+		fail("Error creating CF client: %v", err)
+	}
+
+	// run reports its own failures, including the error event. Queued prompts are
+	// run one after another, each starting from the package of the run before.
+	packageGUID, err := run(client, config)
+	if config.Task {
+		// The exit status of the task is the result of the run
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Prompter task completed successfully")
+		return
 	}
+	var queueErr error
+	for err == nil {
+		next, nextClient, ok, dequeueErr := dequeue(client, config, packageGUID)
+		if dequeueErr != nil {
+			queueErr = dequeueErr
+			break
+		}
+		if !ok {
+			break
+		}
 
-	if err := run(config); err != nil {
+		config, client = next, nextClient
+		fmt.Printf("\nContinuing with queued prompt %s...\n", config.RunID)
+		packageGUID, err = run(client, config)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	stopPrompterApp(client)
+
+	if queueErr != nil {
+		fmt.Printf("Prompter completed, but failed to continue with the prompt queue: %v\n", queueErr)
+		return
+	}
+	fmt.Println("Prompter completed successfully")
+}
+
+// fail reports a failed run to 'cf prompt' and exits
+func fail(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, message)
+	events.NewEmitter(os.Stdout, os.Getenv("RUN_ID")).Emit(events.Event{Type: events.TypeError, Message: message})
+	os.Exit(1)
 }
 
 func loadConfig() (*Config, error) {
-	promptBase64 := os.Getenv("PROMPT_BASE64")
+	// Secrets are read from the run credentials service bound by 'cf prompt', falling
+	// back to environment variables set by earlier versions of the plugin
+	secret := os.Getenv
+	credentials, credentialsService, ok, err := runCredentials(os.Getenv("VCAP_SERVICES"), os.Getenv("RUN_ID"))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		secret = func(key string) string {
+			return credentials[key]
+		}
+	}
+
+	promptBase64 := secret("PROMPT_BASE64")
 	if promptBase64 == "" {
-		return nil, fmt.Errorf("PROMPT_BASE64 environment variable is required")
+		return nil, fmt.Errorf("PROMPT_BASE64 is required")
 	}
 
 	promptBytes, err := base64.StdEncoding.DecodeString(promptBase64)
@@ -53,18 +139,33 @@ func loadConfig() (*Config, error) {
 	}
 
 	config := &Config{
-		AccessToken:      os.Getenv("CF_ACCESS_TOKEN"),
-		API:              os.Getenv("CF_API"),
-		AppID:            os.Getenv("APP_ID"),
-		SpaceID:          os.Getenv("SPACE_ID"),
-		OrgID:            os.Getenv("ORG_ID"),
-		RegistryUsername: os.Getenv("REGISTRY_USERNAME"),
-		RegistryPassword: os.Getenv("REGISTRY_PASSWORD"),
-		Prompt:           string(promptBytes),
+		AccessToken:        secret("CF_ACCESS_TOKEN"),
+		API:                os.Getenv("CF_API"),
+		AppID:              os.Getenv("APP_ID"),
+		SpaceID:            os.Getenv("SPACE_ID"),
+		OrgID:              os.Getenv("ORG_ID"),
+		RegistryUsername:   secret("REGISTRY_USERNAME"),
+		RegistryPassword:   secret("REGISTRY_PASSWORD"),
+		Prompt:             string(promptBytes),
+		BasePackage:        os.Getenv("BASE_PACKAGE"),
+		Continue:           os.Getenv("CONTINUE_CONVERSATION") == "true",
+		ValidateCommand:    os.Getenv("VALIDATE_COMMAND"),
+		ValidateAttempts:   os.Getenv("VALIDATE_ATTEMPTS"),
+		Agent:              os.Getenv("AGENT"),
+		AgentCommand:       os.Getenv("AGENT_COMMAND"),
+		Model:              os.Getenv("MODEL"),
+		Provider:           os.Getenv("PROVIDER"),
+		RunID:              os.Getenv("RUN_ID"),
+		VariantGroup:       os.Getenv("VARIANT_GROUP"),
+		VariantIndex:       os.Getenv("VARIANT_INDEX"),
+		Template:           os.Getenv("TEMPLATE"),
+		TemplateVars:       os.Getenv("TEMPLATE_VARS"),
+		CredentialsService: credentialsService,
+		Task:               os.Getenv("PROMPTER_MODE") == "task",
 	}
 
 	if config.AccessToken == "" {
-		return nil, fmt.Errorf("CF_ACCESS_TOKEN environment variable is required")
+		return nil, fmt.Errorf("CF_ACCESS_TOKEN is required")
 	}
 	if config.API == "" {
 		return nil, fmt.Errorf("CF_API environment variable is required")
@@ -79,78 +180,275 @@ func loadConfig() (*Config, error) {
 	return config, nil
 }
 
-func run(config *Config) error {
+// run executes a single prompt and returns the GUID of the package it created. After
+// a failure the prompter app is stopped, unless the prompter runs as a task.
+func run(client *cfclient.Client, config *Config) (packageGUID string, err error) {
 	workDir, err := os.MkdirTemp("", "cf-prompter-*")
 	if err != nil {
-		return fmt.Errorf("failed to create working directory: %w", err)
+		return "", fmt.Errorf("failed to create working directory: %w", err)
 	}
 	defer os.RemoveAll(workDir)
 
-	fmt.Println("Creating CF client...")
-	client, err := cfclient.New(config.API, config.AccessToken)
+	defer removeRunCredentials(client, config)
+
+	// emitter reports the progress of the run to 'cf prompt' through the log
+	emitter := events.NewEmitter(os.Stdout, config.RunID)
+
+	// The run is cancelled when the platform stops the app, which sends SIGTERM, or
+	// when 'cf prompt-cancel' requests it through an annotation
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+
+	if appGUID := prompterAppGUID(); appGUID != "" && config.RunID != "" {
+		trackRunState(client, appGUID, config, emitter)
+		go watchRun(ctx, cancel, client, appGUID, config.RunID)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if ctx.Err() != nil {
+			fmt.Println("Run cancelled, cleaning up...")
+			emitter.Emit(events.Event{Type: events.TypeCancelled})
+			deleted, err := client.DeletePendingPackages(config.AppID, config.RunID)
+			if err != nil {
+				fmt.Printf("Warning: failed to delete incomplete packages: %v\n", err)
+			}
+			for _, guid := range deleted {
+				fmt.Printf("Deleted incomplete package %s\n", guid)
+			}
+		} else {
+			emitter.Emit(events.Event{Type: events.TypeError, Message: err.Error()})
+		}
+
+		// Stop the prompter after a failure as well, otherwise the platform restarts
+		// it and the prompt is run again. Tasks are not restarted.
+		removeRunCredentials(client, config)
+		if !config.Task {
+			stopPrompterApp(client)
+		}
+	}()
+
+	agentOptions := agent.Options{
+		Command:  config.AgentCommand,
+		Model:    config.Model,
+		Provider: config.Provider,
+	}
+	model := agentOptions.QualifiedModel()
+	codingAgent, err := agent.New(config.Agent, agentOptions)
 	if err != nil {
-		return fmt.Errorf("failed to create CF client: %w", err)
+		return "", fmt.Errorf("failed to select agent: %w", err)
+	}
+
+	if err := codingAgent.EnsureInstalled(); err != nil {
+		return "", fmt.Errorf("failed to install %s: %w", codingAgent.Name(), err)
 	}
 
-	instanceGUID := os.Getenv("CF_INSTANCE_GUID")
-	if instanceGUID == "" {
-		return fmt.Errorf("CF_INSTANCE_GUID environment variable not found")
+	if config.Task {
+		fmt.Printf("Running as CF task for run %s\n", config.RunID)
+	} else {
+		instanceGUID := os.Getenv("CF_INSTANCE_GUID")
+		if instanceGUID == "" {
+			return "", fmt.Errorf("CF_INSTANCE_GUID environment variable not found")
+		}
+		fmt.Printf("Running as CF instance: %s\n", instanceGUID)
 	}
-	fmt.Printf("Running as CF instance: %s\n", instanceGUID)
 
-	fmt.Printf("Getting latest package for app %s...\n", config.AppID)
-	pkg, err := client.GetLatestPackage(config.AppID)
+	emitter.StartPhase(events.PhaseDownload)
+
+	pkg, err := resolveBasePackage(client, config)
 	if err != nil {
-		return fmt.Errorf("failed to get latest package: %w", err)
+		return "", err
 	}
 
 	fmt.Printf("Downloading package %s...\n", pkg.GUID)
 
 	regClient, err := registry.NewClient(config.RegistryUsername, config.RegistryPassword)
 	if err != nil {
-		return fmt.Errorf("failed to create registry client: %w", err)
+		return "", fmt.Errorf("failed to create registry client: %w", err)
 	}
 
-	if err := client.DownloadPackage(pkg, workDir); err != nil {
-		return fmt.Errorf("failed to download package: %w", err)
+	packageDir, err := client.DownloadPackageSource(pkg, workDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to download package: %w", err)
 	}
 
 	fmt.Println("Package downloaded successfully")
 
-	packageDir := filepath.Join(workDir, "app")
-	if _, err := os.Stat(packageDir); os.IsNotExist(err) {
-		packageDir = workDir
+	// The validation settings are read before the agent runs, so it cannot change the
+	// command its changes are checked with
+	validation, err := loadValidationConfig(packageDir, config)
+	if err != nil {
+		return "", err
+	}
+
+	agentPrompt := config.Prompt
+	if config.Continue {
+		history, err := conversationHistory(client, pkg)
+		if err != nil {
+			return "", fmt.Errorf("failed to load conversation history: %w", err)
+		}
+		fmt.Printf("Continuing conversation with %d earlier prompt(s)\n", len(history))
+		agentPrompt = buildConversationPrompt(history, config.Prompt)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
-	fmt.Println("\nExecuting opencode run...")
+	agentName := fmt.Sprintf("%s %s", codingAgent.Name(), codingAgent.Version())
+
+	emitter.StartPhase(events.PhaseAgent)
+	if model != "" {
+		fmt.Printf("\nRunning %s %s with model %s...\n", codingAgent.Name(), codingAgent.Version(), model)
+	} else {
+		fmt.Printf("\nRunning %s %s...\n", codingAgent.Name(), codingAgent.Version())
+	}
 	fmt.Println("================================================================================")
-	if err := opencode.Run(packageDir, config.Prompt, os.Stdout); err != nil {
-		return fmt.Errorf("opencode run failed: %w", err)
+	if err := codingAgent.Run(ctx, packageDir, agentPrompt, os.Stdout); err != nil {
+		return "", fmt.Errorf("%s run failed: %w", codingAgent.Name(), err)
 	}
 	fmt.Println("================================================================================")
+	emitter.Emit(events.Event{Type: events.TypeAgentFinished, Agent: agentName})
+
+	if validation.Command != "" {
+		emitter.StartPhase(events.PhaseValidate)
+		if err := validate(ctx, codingAgent, packageDir, config.Prompt, validation, emitter); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 
+	emitter.StartPhase(events.PhaseUpload)
 	fmt.Println("\nCreating new package revision...")
-	if err := regClient.UploadPackage(client, config.AppID, packageDir, config.Prompt); err != nil {
-		return fmt.Errorf("failed to create new package: %w", err)
-	}
-
-	fmt.Println("Package uploaded successfully - stopping prompter app...")
-	
-	prompterAppGUID := os.Getenv("VCAP_APPLICATION")
-	if prompterAppGUID != "" {
-		var vcapApp map[string]interface{}
-		if err := json.Unmarshal([]byte(prompterAppGUID), &vcapApp); err == nil {
-			if appGUID, ok := vcapApp["application_id"].(string); ok {
-				fmt.Printf("Stopping prompter app %s...\n", appGUID)
-				if err := client.StopApp(appGUID); err != nil {
-					fmt.Printf("Warning: failed to stop prompter app: %v\n", err)
-				} else {
-					fmt.Println("Prompter app stopped successfully")
-				}
-			}
+	annotations := map[string]string{
+		cfclient.AnnotationOriginalPrompt: config.Prompt,
+		cfclient.AnnotationParentPackage:  pkg.GUID,
+		cfclient.AnnotationParentHash:     cfclient.ShortHash(pkg.GUID),
+		cfclient.AnnotationAgent:          agentName,
+	}
+	if model != "" {
+		annotations[cfclient.AnnotationModel] = model
+	}
+	if config.Continue {
+		annotations[cfclient.AnnotationContinues] = cfclient.ShortHash(pkg.GUID)
+	}
+	if validation.Command != "" {
+		annotations[cfclient.AnnotationValidatedWith] = validation.Command
+	}
+	if config.RunID != "" {
+		annotations[cfclient.AnnotationRunID] = config.RunID
+	}
+	if config.VariantGroup != "" {
+		annotations[cfclient.AnnotationVariantGroup] = config.VariantGroup
+		annotations[cfclient.AnnotationVariantIndex] = config.VariantIndex
+	}
+	if config.Template != "" {
+		annotations[cfclient.AnnotationTemplate] = config.Template
+		if config.TemplateVars != "" {
+			annotations[cfclient.AnnotationTemplateVars] = config.TemplateVars
+		}
+	}
+	newPkg, err := regClient.UploadPackage(client, config.AppID, packageDir, annotations)
+	if err != nil {
+		return "", fmt.Errorf("failed to create new package: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		// The upload cannot be interrupted, remove the package it created instead
+		if deleteErr := client.DeletePackage(newPkg.GUID); deleteErr != nil {
+			fmt.Printf("Warning: failed to delete package of cancelled run: %v\n", deleteErr)
 		}
+		return "", err
 	}
+	emitter.Emit(events.Event{Type: events.TypePackageCreated, PackageGUID: newPkg.GUID})
 
-	fmt.Println("Prompter completed successfully")
-	return nil
+	// Stopping the app ends this process, so the credentials are removed and the
+	// result is reported before the caller stops it
+	removeRunCredentials(client, config)
+	emitter.Emit(events.Event{Type: events.TypeCompleted})
+
+	return newPkg.GUID, nil
+}
+
+// resolveBasePackage returns the package the prompt should start from: the latest
+// package by default, the package behind the current droplet for "current", or the
+// package matching a short hash
+func resolveBasePackage(client *cfclient.Client, config *Config) (*resource.Package, error) {
+	switch config.BasePackage {
+	case "":
+		fmt.Printf("Getting latest package for app %s...\n", config.AppID)
+		pkg, err := client.GetLatestPackage(config.AppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest package: %w", err)
+		}
+		return pkg, nil
+	case "current":
+		fmt.Printf("Getting current package for app %s...\n", config.AppID)
+		packageGUID, err := client.GetCurrentDropletPackageGUID(config.AppID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current droplet package: %w", err)
+		}
+		if packageGUID == "" {
+			return nil, fmt.Errorf("app %s has no current droplet", config.AppID)
+		}
+		pkg, err := client.GetPackage(packageGUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current package: %w", err)
+		}
+		return pkg, nil
+	default:
+		fmt.Printf("Getting package %s for app %s...\n", config.BasePackage, config.AppID)
+		pkg, err := client.FindPackageByShortHash(config.AppID, config.BasePackage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find base package: %w", err)
+		}
+		return pkg, nil
+	}
+}
+
+// validate runs the validation command and lets the agent fix failures for a bounded
+// number of attempts. It returns an error if validation still fails afterwards.
+func validate(ctx context.Context, codingAgent agent.Agent, packageDir, prompt string, validation validationConfig, emitter *events.Emitter) error {
+	for attempt := 0; ; attempt++ {
+		fmt.Printf("\nValidating changes with '%s'...\n", validation.Command)
+		output, failure, err := runValidation(ctx, packageDir, validation.Command, os.Stdout)
+		if err != nil {
+			return err
+		}
+		emitter.Emit(events.Event{Type: events.TypeValidation, Passed: failure == nil, Attempt: attempt})
+		if failure == nil {
+			fmt.Println("Validation succeeded")
+			return nil
+		}
+
+		if attempt == validation.Attempts {
+			return fmt.Errorf("validation failed after %d fix-up attempt(s), not creating a package: %w", validation.Attempts, failure)
+		}
+
+		fmt.Printf("Validation failed (%v), asking %s to fix it (attempt %d/%d)...\n", failure, codingAgent.Name(), attempt+1, validation.Attempts)
+		fmt.Println("================================================================================")
+		if err := codingAgent.Run(ctx, packageDir, buildFixPrompt(prompt, validation.Command, output), os.Stdout); err != nil {
+			return fmt.Errorf("%s fix-up run failed: %w", codingAgent.Name(), err)
+		}
+		fmt.Println("================================================================================")
+	}
+}
+
+// removeRunCredentials deletes the credentials handed over for this run. Failures are
+// only reported, 'cf prompt' removes the credentials as well once the run is over.
+func removeRunCredentials(client *cfclient.Client, config *Config) {
+	if config.CredentialsService == "" {
+		return
+	}
+	defer func() { config.CredentialsService = "" }()
+
+	fmt.Println("Removing run credentials...")
+	if err := client.DeleteRunCredentials(config.CredentialsService, config.SpaceID); err != nil {
+		fmt.Printf("Warning: failed to remove run credentials: %v\n", err)
+	}
 }
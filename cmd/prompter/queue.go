@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// dequeue returns the config and client for the oldest queued prompt, ok is false if there is none
+func dequeue(client *cfclient.Client, config *Config, packageGUID string) (next *Config, nextClient *cfclient.Client, ok bool, err error) {
+	appGUID := prompterAppGUID()
+	if appGUID == "" {
+		return nil, nil, false, nil
+	}
+
+	annotations, err := client.GetAppAnnotations(appGUID)
+	if err != nil {
+		releaseLock(client, appGUID, config.RunID)
+		return nil, nil, false, err
+	}
+
+	// A forced run that broke the lock owns the prompter now, the queue is left to it
+	if annotations[runstate.KeyLockRunID] != config.RunID {
+		return nil, nil, false, nil
+	}
+
+	queue := runstate.Queue(annotations)
+	if len(queue) == 0 {
+		return nil, nil, false, nil
+	}
+	entry := queue[0]
+
+	// The run uses the credentials stored with the prompt, never those of the run before
+	credentials, err := entryCredentials(client, entry, config.SpaceID)
+	if err == nil {
+		next = queuedConfig(config, entry, packageGUID, credentials)
+		nextClient, err = cfclient.New(next.API, next.AccessToken)
+	}
+	if err != nil {
+		failEntry(client, appGUID, config.SpaceID, entry, fmt.Sprintf("failed to read the credentials of the queued prompt, queue it again: %v", err))
+		return nil, nil, false, fmt.Errorf("failed to read the credentials of queued prompt %s: %w", entry.ID, err)
+	}
+	// The token was valid when the prompt was queued, but the runs before may have
+	// taken longer than it lasts
+	if tokenExpired(next.AccessToken, time.Now()) {
+		failEntry(client, appGUID, config.SpaceID, entry, "token expired, queue it again")
+		return nil, nil, false, fmt.Errorf("the token of queued prompt %s expired", entry.ID)
+	}
+
+	// The state and lock of the prompter are handed over to the new run in one update
+	state := runstate.New(entry.ID, entry.Prompt)
+	state.AppGUID = next.AppID
+	update := state.Annotations()
+	for key, value := range runstate.NewLock(entry.SubmittedBy, entry.ID).Annotations() {
+		update[key] = value
+	}
+	update[entry.Key()] = ""
+	if err := client.UpdateAppAnnotations(appGUID, update); err != nil {
+		releaseLock(client, appGUID, config.RunID)
+		return nil, nil, false, err
+	}
+
+	return next, nextClient, true, nil
+}
+
+// entryCredentials reads the credentials stored for a queued prompt
+func entryCredentials(client *cfclient.Client, entry runstate.QueueEntry, spaceGUID string) (map[string]string, error) {
+	if entry.CredentialsService == "" {
+		return nil, fmt.Errorf("no credentials were stored with the prompt")
+	}
+
+	credentials, err := client.GetRunCredentials(entry.CredentialsService, spaceGUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEntryCredentials(credentials, entry.ID); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// checkEntryCredentials makes sure the credentials belong to the queued prompt and hold a token
+func checkEntryCredentials(credentials map[string]string, entryID string) error {
+	if credentials["run_id"] != entryID {
+		return fmt.Errorf("credentials belong to run %q", credentials["run_id"])
+	}
+	if credentials["CF_ACCESS_TOKEN"] == "" {
+		return fmt.Errorf("credentials hold no CF_ACCESS_TOKEN")
+	}
+	return nil
+}
+
+// tokenExpired reports whether the JWT access token expired before now. Tokens without
+// a readable expiry are left to the API to reject.
+func tokenExpired(token string, now time.Time) bool {
+	// The token may carry the "bearer " prefix of the CF CLI
+	parts := strings.Split(token[strings.LastIndex(token, " ")+1:], ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return false
+	}
+	return !now.Before(time.Unix(claims.Exp, 0))
+}
+
+// queuedConfig returns the configuration of the run of a queued prompt, following the
+// run of config that created packageGUID
+func queuedConfig(config *Config, entry runstate.QueueEntry, packageGUID string, credentials map[string]string) *Config {
+	appID := config.AppID
+	basePackage := cfclient.ShortHash(packageGUID)
+	if entry.AppGUID != "" && entry.AppGUID != config.AppID {
+		appID = entry.AppGUID
+		basePackage = ""
+	}
+
+	validateAttempts := ""
+	if entry.ValidateAttempts > 0 {
+		validateAttempts = strconv.Itoa(entry.ValidateAttempts)
+	}
+
+	queued := *config
+	queued.AccessToken = credentials["CF_ACCESS_TOKEN"]
+	queued.RegistryUsername = credentials["REGISTRY_USERNAME"]
+	queued.RegistryPassword = credentials["REGISTRY_PASSWORD"]
+	queued.CredentialsService = entry.CredentialsService
+	queued.Prompt = entry.Prompt
+	queued.AppID = appID
+	queued.BasePackage = basePackage
+	queued.Continue = false
+	queued.ValidateCommand = entry.Validate
+	queued.ValidateAttempts = validateAttempts
+	queued.Agent = entry.Agent
+	queued.AgentCommand = entry.AgentCommand
+	queued.Model = entry.Model
+	queued.Provider = entry.Provider
+	queued.RunID = entry.ID
+	queued.VariantGroup = ""
+	queued.VariantIndex = ""
+	queued.Template = entry.Template
+	queued.TemplateVars = entry.TemplateVars
+	return &queued
+}
+
+// failEntry records a queued prompt that cannot run as a failed run and removes it from the queue
+func failEntry(client *cfclient.Client, appGUID, spaceGUID string, entry runstate.QueueEntry, message string) {
+	state := runstate.New(entry.ID, entry.Prompt)
+	state.AppGUID = entry.AppGUID
+	state.Apply(events.Event{Type: events.TypeError, Message: message})
+
+	// The lock kept for the prompt is released in the same update
+	update := state.Annotations()
+	for key, value := range runstate.ReleaseAnnotations() {
+		update[key] = value
+	}
+	update[entry.Key()] = ""
+	if err := client.UpdateAppAnnotations(appGUID, update); err != nil {
+		fmt.Printf("Warning: failed to record failed queued prompt: %v\n", err)
+	}
+
+	if entry.CredentialsService != "" {
+		if err := client.DeleteRunCredentials(entry.CredentialsService, spaceGUID); err != nil {
+			fmt.Printf("Warning: failed to remove run credentials: %v\n", err)
+		}
+	}
+}
+
+// releaseLock releases the lock the prompter kept for a queued prompt it cannot run, if
+// the run is still the one holding it
+func releaseLock(client *cfclient.Client, appGUID, runID string) {
+	annotations, err := client.GetAppAnnotations(appGUID)
+	if err != nil || annotations[runstate.KeyLockRunID] != runID {
+		return
+	}
+	if err := client.UpdateAppAnnotations(appGUID, runstate.ReleaseAnnotations()); err != nil {
+		fmt.Printf("Warning: failed to release prompter lock: %v\n", err)
+	}
+}
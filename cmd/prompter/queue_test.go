@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+func TestQueuedConfig(t *testing.T) {
+	previous := &Config{
+		AccessToken:        "token-of-alice",
+		RegistryUsername:   "alice",
+		RegistryPassword:   "secret-of-alice",
+		CredentialsService: "app-prompter-run-a1",
+		API:                "https://api.example.com",
+		AppID:              "app-1",
+		SpaceID:            "space-1",
+		Prompt:             "add logging",
+		Continue:           true,
+		RunID:              "a1",
+		VariantGroup:       "g1",
+		VariantIndex:       "1",
+	}
+	credentials := map[string]string{
+		"run_id":            "b2",
+		"CF_ACCESS_TOKEN":   "token-of-bob",
+		"REGISTRY_USERNAME": "bob",
+		"REGISTRY_PASSWORD": "secret-of-bob",
+	}
+
+	tests := []struct {
+		name        string
+		entry       runstate.QueueEntry
+		appID       string
+		basePackage string
+	}{
+		{
+			name:        "Same app",
+			entry:       runstate.QueueEntry{ID: "b2", Prompt: "bump timeout", ValidateAttempts: 2, CredentialsService: "app-prompter-run-b2"},
+			appID:       "app-1",
+			basePackage: cfclient.ShortHash("package-1"),
+		},
+		{
+			name:  "Other app on a shared prompter",
+			entry: runstate.QueueEntry{ID: "b2", AppGUID: "app-2", Prompt: "bump timeout", ValidateAttempts: 2, CredentialsService: "app-prompter-run-b2"},
+			appID: "app-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queued := queuedConfig(previous, tt.entry, "package-1", credentials)
+
+			if queued.AccessToken != "token-of-bob" || queued.RegistryUsername != "bob" || queued.RegistryPassword != "secret-of-bob" {
+				t.Errorf("Expected the credentials of the queued prompt, got %q, %q, %q", queued.AccessToken, queued.RegistryUsername, queued.RegistryPassword)
+			}
+			if queued.CredentialsService != "app-prompter-run-b2" {
+				t.Errorf("Expected credentials service app-prompter-run-b2, got %q", queued.CredentialsService)
+			}
+			if queued.RunID != "b2" || queued.Prompt != "bump timeout" || queued.ValidateAttempts != "2" {
+				t.Errorf("Expected the run of the queued prompt, got run %q, prompt %q, attempts %q", queued.RunID, queued.Prompt, queued.ValidateAttempts)
+			}
+			if queued.AppID != tt.appID || queued.BasePackage != tt.basePackage {
+				t.Errorf("Expected app %q from %q, got %q from %q", tt.appID, tt.basePackage, queued.AppID, queued.BasePackage)
+			}
+			if queued.Continue || queued.VariantGroup != "" || queued.VariantIndex != "" {
+				t.Error("Expected the options of the previous run to be reset")
+			}
+			if previous.AccessToken != "token-of-alice" || previous.RunID != "a1" {
+				t.Error("Expected the configuration of the previous run to be unchanged")
+			}
+		})
+	}
+}
+
+func TestCheckEntryCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "Credentials of the entry",
+			credentials: map[string]string{"run_id": "b2", "CF_ACCESS_TOKEN": "token"},
+		},
+		{
+			name:        "Credentials of another run",
+			credentials: map[string]string{"run_id": "a1", "CF_ACCESS_TOKEN": "token"},
+			wantErr:     true,
+		},
+		{
+			name:        "No token",
+			credentials: map[string]string{"run_id": "b2"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEntryCredentials(tt.credentials, "b2")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		expired bool
+	}{
+		{
+			name:  "Valid token",
+			token: token(`{"exp":1700003600}`),
+		},
+		{
+			name:    "Expired token",
+			token:   token(`{"exp":1699999999}`),
+			expired: true,
+		},
+		{
+			name:    "Expired token with bearer prefix",
+			token:   "bearer " + token(`{"exp":1699999999}`),
+			expired: true,
+		},
+		{
+			name:  "No expiry",
+			token: token(`{"user_name":"alice"}`),
+		},
+		{
+			name:  "Not a JWT",
+			token: "opaque-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expired := tokenExpired(tt.token, now); expired != tt.expired {
+				t.Errorf("Expected expired=%v, got %v", tt.expired, expired)
+			}
+		})
+	}
+}
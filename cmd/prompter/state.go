@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// prompterAppGUID returns the GUID of the app the prompter runs in, or an empty string
+// if it does not run in a CF app
+func prompterAppGUID() string {
+	var vcapApp struct {
+		ApplicationID string `json:"application_id"`
+	}
+	if err := json.Unmarshal([]byte(os.Getenv("VCAP_APPLICATION")), &vcapApp); err != nil {
+		return ""
+	}
+	return vcapApp.ApplicationID
+}
+
+// annotationsMu serializes the updates of the prompter's annotations by a run
+var annotationsMu sync.Mutex
+
+// trackRunState records the events of emitter in the run state annotations of the prompter app
+func trackRunState(client *cfclient.Client, appGUID string, config *Config, emitter *events.Emitter) {
+	runID := config.RunID
+	state := runstate.New(runID, "")
+	state.AppGUID = config.AppID
+	holdsLock := false
+	if annotations, err := client.GetAppAnnotations(appGUID); err == nil {
+		if recorded, ok := runstate.FromAnnotations(annotations); ok && recorded.RunID == runID {
+			state = recorded
+		}
+		if recorded, ok := runstate.LockFromAnnotations(annotations); ok && recorded.RunID == runID {
+			holdsLock = true
+		}
+	}
+
+	emitter.OnEmit(func(event events.Event) {
+		if !state.Apply(event) {
+			return
+		}
+
+		annotationsMu.Lock()
+		defer annotationsMu.Unlock()
+
+		current, err := client.GetAppAnnotations(appGUID)
+		if err != nil {
+			fmt.Printf("Warning: failed to read run state: %v\n", err)
+			return
+		}
+		annotations, ok := stateUpdate(state, current, holdsLock, config.Task)
+		if !ok {
+			return
+		}
+		if err := client.UpdateAppAnnotations(appGUID, annotations); err != nil {
+			fmt.Printf("Warning: failed to record run state: %v\n", err)
+		}
+	})
+}
+
+// stateUpdate returns the annotations recording state, ok is false if the run must leave them alone
+func stateUpdate(state runstate.State, current map[string]string, holdsLock, task bool) (update map[string]string, ok bool) {
+	// Parallel task runs share the annotations, a later run has been recorded
+	if task && current[runstate.KeyRunID] != state.RunID {
+		return nil, false
+	}
+	// The run lost the lock to 'cf prompt --force'
+	if holdsLock && current[runstate.KeyLockRunID] != state.RunID {
+		return nil, false
+	}
+
+	update = state.Annotations()
+	// A cancellation request is done with once the run has ended
+	if state.Finished() && current[runstate.KeyCancel] == state.RunID {
+		update[runstate.KeyCancel] = ""
+	}
+	if holdsLock {
+		lock := runstate.NewLock(current[runstate.KeyLockOwner], state.RunID).Annotations()
+		// A succeeded run keeps the lock for the next queued prompt
+		if state.Finished() && !(state.Status == runstate.StatusSucceeded && len(runstate.Queue(current)) > 0) {
+			lock = runstate.ReleaseAnnotations()
+		}
+		for key, value := range lock {
+			update[key] = value
+		}
+	}
+	return update, true
+}
+
+// stopPrompterApp stops the app the prompter runs in, which ends this process
+func stopPrompterApp(client *cfclient.Client) {
+	appGUID := prompterAppGUID()
+	if appGUID == "" {
+		return
+	}
+
+	fmt.Printf("Stopping prompter app %s...\n", appGUID)
+	if err := client.StopApp(appGUID); err != nil {
+		fmt.Printf("Warning: failed to stop prompter app: %v\n", err)
+	} else {
+		fmt.Println("Prompter app stopped successfully")
+	}
+}
+
+// watchRun cancels the run when 'cf prompt-cancel' requests it and renews the lease of
+// the lock the run holds
+func watchRun(ctx context.Context, cancel context.CancelFunc, client *cfclient.Client, appGUID, runID string) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			annotations, err := client.GetAppAnnotations(appGUID)
+			if err != nil {
+				continue
+			}
+			if annotations[runstate.KeyCancel] == runID {
+				fmt.Println("Cancellation requested")
+				cancel()
+				return
+			}
+			if _, ok := leaseRenewal(annotations, runID, time.Now()); ok {
+				renewLease(client, appGUID, runID)
+			}
+		}
+	}
+}
+
+// renewLease renews the lease of the lock if the run still holds it
+func renewLease(client *cfclient.Client, appGUID, runID string) {
+	annotationsMu.Lock()
+	defer annotationsMu.Unlock()
+
+	// Read again, the run may have released the lock since
+	annotations, err := client.GetAppAnnotations(appGUID)
+	if err != nil {
+		return
+	}
+	if update, ok := leaseRenewal(annotations, runID, time.Now()); ok {
+		if err := client.UpdateAppAnnotations(appGUID, update); err != nil {
+			fmt.Printf("Warning: failed to renew prompter lock: %v\n", err)
+		}
+	}
+}
+
+// leaseRenewal returns the annotation update that renews the lease of the run's lock,
+// ok is false if the run does not hold the lock or it is not due yet
+func leaseRenewal(annotations map[string]string, runID string, now time.Time) (update map[string]string, ok bool) {
+	lock, held := runstate.LockFromAnnotations(annotations)
+	if !held || lock.RunID != runID || !lock.Renewable(now) {
+		return nil, false
+	}
+	return runstate.NewLock(lock.Owner, runID).Annotations(), true
+}
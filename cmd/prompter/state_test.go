@@ -0,0 +1,158 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+func TestStateUpdate(t *testing.T) {
+	annotations := func(runID, lockRunID string, queued bool) map[string]string {
+		current := runstate.New(runID, "").Annotations()
+		if lockRunID != "" {
+			for key, value := range runstate.NewLock("developer@example.com", lockRunID).Annotations() {
+				current[key] = value
+			}
+		}
+		if queued {
+			entry, _ := runstate.QueueEntry{ID: "c3", Prompt: "bump timeout"}.Annotations()
+			for key, value := range entry {
+				current[key] = value
+			}
+		}
+		return current
+	}
+
+	running := runstate.New("a1", "")
+	running.Apply(events.Event{Type: events.TypePhaseStarted, Phase: events.PhaseAgent})
+	succeeded := running
+	succeeded.Apply(events.Event{Type: events.TypeCompleted})
+
+	tests := []struct {
+		name      string
+		state     runstate.State
+		current   map[string]string
+		holdsLock bool
+		task      bool
+		ok        bool
+		lockRunID string
+	}{
+		{
+			name:      "Renew the lock",
+			state:     running,
+			current:   annotations("a1", "a1", false),
+			holdsLock: true,
+			ok:        true,
+			lockRunID: "a1",
+		},
+		{
+			name:      "Release the lock",
+			state:     succeeded,
+			current:   annotations("a1", "a1", false),
+			holdsLock: true,
+			ok:        true,
+		},
+		{
+			name:      "Keep the lock for a queued prompt",
+			state:     succeeded,
+			current:   annotations("a1", "a1", true),
+			holdsLock: true,
+			ok:        true,
+			lockRunID: "a1",
+		},
+		{
+			name:      "Lock broken by a forced run",
+			state:     succeeded,
+			current:   annotations("b2", "b2", false),
+			holdsLock: true,
+		},
+		{
+			name:    "Task run followed by a later run",
+			state:   running,
+			current: annotations("b2", "", false),
+			task:    true,
+		},
+		{
+			name:    "Task run",
+			state:   running,
+			current: annotations("a1", "", false),
+			task:    true,
+			ok:      true,
+		},
+	}
+
+	cancelled := running
+	cancelled.Apply(events.Event{Type: events.TypeCancelled})
+	cancelRequested := annotations("a1", "", false)
+	cancelRequested[runstate.KeyCancel] = "a1"
+
+	t.Run("Clear the cancellation request", func(t *testing.T) {
+		if update, ok := stateUpdate(running, cancelRequested, false, false); !ok || update[runstate.KeyCancel] != "" || len(update) != len(running.Annotations()) {
+			t.Errorf("Expected the request to be kept while the run goes on, got %v", update)
+		}
+		update, ok := stateUpdate(cancelled, cancelRequested, false, false)
+		if value, cleared := update[runstate.KeyCancel]; !ok || !cleared || value != "" {
+			t.Errorf("Expected the request to be cleared, got %v", update)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, ok := stateUpdate(tt.state, tt.current, tt.holdsLock, tt.task)
+
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if update[runstate.KeyRunID] != tt.state.RunID {
+				t.Errorf("Expected the state of run %s, got %v", tt.state.RunID, update)
+			}
+			lockRunID, lockUpdated := update[runstate.KeyLockRunID]
+			if tt.holdsLock && (!lockUpdated || lockRunID != tt.lockRunID) {
+				t.Errorf("Expected lock run ID %q, got %q", tt.lockRunID, lockRunID)
+			}
+			if !tt.holdsLock && lockUpdated {
+				t.Errorf("Expected the lock not to be touched, got %v", update)
+			}
+		})
+	}
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	now := time.Now()
+	lock := func(runID string, expiresIn time.Duration) map[string]string {
+		return runstate.Lock{Owner: "developer@example.com", RunID: runID, ExpiresAt: now.Add(expiresIn)}.Annotations()
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ok          bool
+	}{
+		{name: "Lease due", annotations: lock("a1", 10*time.Minute), ok: true},
+		{name: "Lease expired", annotations: lock("a1", -time.Minute), ok: true},
+		{name: "Lease renewed recently", annotations: lock("a1", runstate.LeaseDuration-time.Minute)},
+		{name: "Lock of another run", annotations: lock("b2", 10*time.Minute)},
+		{name: "No lock", annotations: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, ok := leaseRenewal(tt.annotations, "a1", now)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			renewed, _ := runstate.LockFromAnnotations(update)
+			if renewed.RunID != "a1" || renewed.Owner != "developer@example.com" || renewed.Renewable(now) {
+				t.Errorf("Expected a renewed lock of run a1, got %+v", renewed)
+			}
+		})
+	}
+}
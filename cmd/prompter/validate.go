@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/agent"
+	"gopkg.in/yaml.v3"
+)
+
+// appConfigFile is the optional prompter configuration file in the root of the app source
+const appConfigFile = ".cf-prompt.yml"
+
+const (
+	defaultValidateAttempts = 3
+	// maxValidationFeedback bounds how much validation output is passed back to the agent
+	maxValidationFeedback = 4000
+)
+
+type validationConfig struct {
+	Command  string `yaml:"command"`
+	Attempts int    `yaml:"attempts"`
+}
+
+type appConfig struct {
+	Validate validationConfig `yaml:"validate"`
+}
+
+// loadValidationConfig reads the validation settings from the app source and applies
+// the overrides passed by 'cf prompt'
+func loadValidationConfig(packageDir string, config *Config) (validationConfig, error) {
+	var cfg appConfig
+
+	content, err := os.ReadFile(filepath.Join(packageDir, appConfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return validationConfig{}, fmt.Errorf("failed to read %s: %w", appConfigFile, err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(content, &cfg); err != nil {
+			return validationConfig{}, fmt.Errorf("failed to parse %s: %w", appConfigFile, err)
+		}
+	}
+
+	validation := cfg.Validate
+	if config.ValidateCommand != "" {
+		validation.Command = config.ValidateCommand
+	}
+	if config.ValidateAttempts != "" {
+		attempts, err := strconv.Atoi(config.ValidateAttempts)
+		if err != nil {
+			return validationConfig{}, fmt.Errorf("invalid VALIDATE_ATTEMPTS: %w", err)
+		}
+		validation.Attempts = attempts
+	}
+	if validation.Attempts <= 0 {
+		validation.Attempts = defaultValidateAttempts
+	}
+
+	return validation, nil
+}
+
+// runValidation runs the validation command on a copy of sourceDir, so build artifacts
+// do not end up in the package. It returns the combined output for feedback to the
+// agent and the command's failure, if any. err is only set if validation could not run.
+func runValidation(ctx context.Context, sourceDir, command string, stdout io.Writer) (output string, failure error, err error) {
+	workDir, err := os.MkdirTemp("", "cf-prompter-validate-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create validation directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	if err := copyDir(sourceDir, workDir); err != nil {
+		return "", nil, fmt.Errorf("failed to copy source for validation: %w", err)
+	}
+
+	var captured bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = workDir
+	cmd.Env = agent.Environ()
+	cmd.Stdout = io.MultiWriter(stdout, &captured)
+	cmd.Stderr = io.MultiWriter(stdout, &captured)
+
+	failure = cmd.Run()
+	return captured.String(), failure, nil
+}
+
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
+// buildFixPrompt asks the agent to fix the code after a failed validation
+func buildFixPrompt(prompt, command, output string) string {
+	if len(output) > maxValidationFeedback {
+		output = "...\n" + output[len(output)-maxValidationFeedback:]
+	}
+
+	return fmt.Sprintf(`You were asked to apply the following instruction to the code in this directory:
+
+%s
+
+After your changes, the validation command '%s' fails with the following output:
+
+%s
+
+Fix the code so that the validation command succeeds, while keeping the requested changes.`, prompt, command, output)
+}
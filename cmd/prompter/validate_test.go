@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadValidationConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		config     Config
+		expected   validationConfig
+		shouldFail bool
+	}{
+		{
+			name:     "No config file",
+			expected: validationConfig{Attempts: defaultValidateAttempts},
+		},
+		{
+			name:     "Config file",
+			file:     "validate:\n  command: go test ./...\n  attempts: 5\n",
+			expected: validationConfig{Command: "go test ./...", Attempts: 5},
+		},
+		{
+			name:     "Flags override the config file",
+			file:     "validate:\n  command: go test ./...\n  attempts: 5\n",
+			config:   Config{ValidateCommand: "make check", ValidateAttempts: "2"},
+			expected: validationConfig{Command: "make check", Attempts: 2},
+		},
+		{
+			name:     "Attempts default",
+			file:     "validate:\n  command: npm test\n",
+			expected: validationConfig{Command: "npm test", Attempts: defaultValidateAttempts},
+		},
+		{
+			name:       "Invalid config file",
+			file:       "validate: [",
+			shouldFail: true,
+		},
+		{
+			name:       "Invalid attempts",
+			config:     Config{ValidateAttempts: "many"},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, appConfigFile), []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			validation, err := loadValidationConfig(dir, &tt.config)
+
+			if tt.shouldFail != (err != nil) {
+				t.Fatalf("Expected failure=%v, got %v", tt.shouldFail, err)
+			}
+			if !tt.shouldFail && validation != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, validation)
+			}
+		})
+	}
+}
+
+func TestRunValidation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, failure, err := runValidation(context.Background(), dir, "cat main.txt && touch artifact", io.Discard)
+	if err != nil || failure != nil {
+		t.Fatalf("Expected validation to pass, got failure=%v, err=%v", failure, err)
+	}
+	if output != "source" {
+		t.Errorf("Expected output %q, got %q", "source", output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "artifact")); !os.IsNotExist(err) {
+		t.Errorf("Expected validation to run on a copy of the source")
+	}
+
+	output, failure, err = runValidation(context.Background(), dir, "echo broken && exit 1", io.Discard)
+	if err != nil || failure == nil {
+		t.Fatalf("Expected validation to fail, got failure=%v, err=%v", failure, err)
+	}
+	if !strings.Contains(output, "broken") {
+		t.Errorf("Expected the output of the failed command, got %q", output)
+	}
+}
+
+func TestRunValidationEnvironment(t *testing.T) {
+	t.Setenv("VCAP_SERVICES", `{"user-provided":[{"name":"app-prompter-run-1","tags":["cf-prompt-run"],"credentials":{"run_id":"1","CF_ACCESS_TOKEN":"token-1"}}]}`)
+	t.Setenv("ANTHROPIC_API_KEY", "provider-key")
+
+	output, failure, err := runValidation(context.Background(), t.TempDir(), "env", io.Discard)
+	if err != nil || failure != nil {
+		t.Fatalf("Expected validation to pass, got failure=%v, err=%v", failure, err)
+	}
+	if strings.Contains(output, "cf-prompt-run") || strings.Contains(output, "token-1") {
+		t.Error("Expected the run credentials not to be visible to the validation command")
+	}
+	if !strings.Contains(output, "ANTHROPIC_API_KEY=provider-key") {
+		t.Error("Expected the provider credentials in the environment of the validation command")
+	}
+}
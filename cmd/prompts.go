@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
 )
 
@@ -74,14 +76,63 @@ func (t *simpleTable) print() {
 	}
 }
 
+// PromptsOptions holds the parsed arguments of the prompts command
+type PromptsOptions struct {
+	App    string
+	Graph  bool
+	Output string
+}
+
+// packageInfo is the machine-readable representation of a package in 'cf prompts --output'
+type packageInfo struct {
+	GUID          string            `json:"guid" yaml:"guid"`
+	Hash          string            `json:"hash" yaml:"hash"`
+	State         string            `json:"state" yaml:"state"`
+	DropletStatus string            `json:"droplet_status" yaml:"droplet_status"`
+	DropletGUID   string            `json:"droplet_guid,omitempty" yaml:"droplet_guid,omitempty"`
+	CreatedAt     time.Time         `json:"created_at" yaml:"created_at"`
+	Type          string            `json:"type" yaml:"type"`
+	Prompt        string            `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	Current       bool              `json:"current" yaml:"current"`
+	Annotations   map[string]string `json:"annotations" yaml:"annotations"`
+}
+
+// ParsePromptsArgs parses command line arguments for prompts and returns the options and whether parsing failed
+func ParsePromptsArgs(args []string) (opts PromptsOptions, failed bool) {
+	var nonFlagArgs []string
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--graph" {
+			opts.Graph = true
+		} else if (args[i] == "--output" || args[i] == "-o") && i+1 < len(args) {
+			opts.Output = args[i+1]
+			i++
+		} else {
+			nonFlagArgs = append(nonFlagArgs, args[i])
+		}
+	}
+
+	if len(nonFlagArgs) != 1 {
+		return PromptsOptions{}, true
+	}
+
+	if opts.Output != "" && (!validOutputFormat(opts.Output) || opts.Graph) {
+		return PromptsOptions{}, true
+	}
+	opts.App = nonFlagArgs[0]
+
+	return opts, false
+}
+
 func PromptsCommand(cliConnection plugin.CliConnection, args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No app name provided")
-		fmt.Println("Usage: cf prompts <APP_NAME>")
+	opts, failed := ParsePromptsArgs(args)
+	if failed {
+		fmt.Println("Error: Invalid arguments")
+		fmt.Println("Usage: cf prompts <APP_NAME> [--graph | --output json|yaml]")
 		os.Exit(1)
 	}
 
-	appName := args[0]
+	appName := opts.App
 
 	apiEndpoint, err := cliConnection.ApiEndpoint()
 	if err != nil {
@@ -125,6 +176,19 @@ func PromptsCommand(cliConnection plugin.CliConnection, args []string) {
 		os.Exit(1)
 	}
 
+	if opts.Output != "" {
+		infos := make([]packageInfo, 0, len(packages))
+		for _, pkg := range packages {
+			infos = append(infos, newPackageInfo(client, pkg, currentPackageGUID))
+		}
+
+		if err := writeStructuredOutput(os.Stdout, opts.Output, infos); err != nil {
+			fmt.Printf("Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	currentOrg, err := cliConnection.GetCurrentOrg()
 	if err != nil {
 		fmt.Printf("Error getting current org: %v\n", err)
@@ -144,7 +208,21 @@ func PromptsCommand(cliConnection plugin.CliConnection, args []string) {
 		return
 	}
 
-	table := newSimpleTable([]string{"hash", "state", "droplet", "created", "type", "original prompt"})
+	// Variants of the same prompt are listed together, with a column naming their group
+	packages = groupVariants(packages)
+	hasVariants := false
+	for _, pkg := range packages {
+		if variantLabel(pkg) != "" {
+			hasVariants = true
+		}
+	}
+
+	headers := []string{"hash", "state", "droplet", "created", "type", "original prompt"}
+	if hasVariants {
+		headers = []string{"hash", "state", "droplet", "created", "type", "variant", "original prompt"}
+	}
+	table := newSimpleTable(headers)
+	var nodes []graphNode
 
 	for _, pkg := range packages {
 		hash := cfclient.ShortHash(pkg.GUID)
@@ -171,8 +249,53 @@ func PromptsCommand(cliConnection plugin.CliConnection, args []string) {
 			dropletStatus = "unknown"
 		}
 
-		table.addRow(hash, state, dropletStatus, createdAt, pkg.Type, prompt)
+		if hasVariants {
+			table.addRow(hash, state, dropletStatus, createdAt, pkg.Type, variantLabel(pkg), prompt)
+		} else {
+			table.addRow(hash, state, dropletStatus, createdAt, pkg.Type, prompt)
+		}
+
+		parentGUID, _ := client.GetParentPackageGUID(pkg)
+		nodes = append(nodes, graphNode{
+			guid:   pkg.GUID,
+			parent: parentGUID,
+			label:  fmt.Sprintf("%s  %s  %s  %s  %s", hash, state, dropletStatus, createdAt, prompt),
+		})
+	}
+
+	if opts.Graph {
+		for _, line := range renderGraph(nodes) {
+			fmt.Println(line)
+		}
+		return
 	}
 
 	table.print()
 }
+
+func newPackageInfo(client *cfclient.Client, pkg *resource.Package, currentPackageGUID string) packageInfo {
+	prompt, _ := client.GetOriginalPrompt(pkg)
+
+	dropletStatus, err := client.GetPackageDropletStatus(pkg.GUID)
+	if err != nil {
+		dropletStatus = "unknown"
+	}
+
+	dropletGUID, err := client.GetPackageDropletGUID(pkg.GUID)
+	if err != nil {
+		dropletGUID = ""
+	}
+
+	return packageInfo{
+		GUID:          pkg.GUID,
+		Hash:          cfclient.ShortHash(pkg.GUID),
+		State:         strings.ToLower(string(pkg.State)),
+		DropletStatus: dropletStatus,
+		DropletGUID:   dropletGUID,
+		CreatedAt:     pkg.CreatedAt,
+		Type:          pkg.Type,
+		Prompt:        prompt,
+		Current:       currentPackageGUID != "" && pkg.GUID == currentPackageGUID,
+		Annotations:   cfclient.GetPluginAnnotations(pkg),
+	}
+}
@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
 )
 
 func TestArgumentParsing(t *testing.T) {
@@ -74,6 +80,184 @@ func TestArgumentParsing(t *testing.T) {
 	}
 }
 
+func TestPromptOptionsParsing(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expected   PromptOptions
+		shouldFail bool
+	}{
+		{
+			name:     "Base package hash",
+			args:     []string{"test", "-p", "add logging", "--from", "a1b2c3d"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", From: "a1b2c3d"},
+		},
+		{
+			name:     "Current base package",
+			args:     []string{"test", "--from-current", "-p", "add logging"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", FromCurrent: true},
+		},
+		{
+			name:     "Continue conversation",
+			args:     []string{"test", "--continue", "a1b2c3d", "-p", "now add tests"},
+			expected: PromptOptions{App: "test", Prompt: "now add tests", Continue: "a1b2c3d"},
+		},
+		{
+			name:       "Continue with explicit base package",
+			args:       []string{"test", "--continue", "a1b2c3d", "--from", "e4f5a6b", "-p", "now add tests"},
+			shouldFail: true,
+		},
+		{
+			name:     "Validation command",
+			args:     []string{"test", "-p", "add logging", "--validate", "go test ./...", "--validate-attempts", "5"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Validate: "go test ./...", ValidateAttempts: 5},
+		},
+		{
+			name:       "Invalid validation attempts",
+			args:       []string{"test", "-p", "add logging", "--validate-attempts", "0"},
+			shouldFail: true,
+		},
+		{
+			name:     "Aider agent",
+			args:     []string{"test", "-p", "add logging", "--agent", "aider"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Agent: "aider"},
+		},
+		{
+			name:     "Custom agent command",
+			args:     []string{"test", "-p", "add logging", "--agent-command", "mytool --message \"$PROMPT\""},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Agent: "command", AgentCommand: "mytool --message \"$PROMPT\""},
+		},
+		{
+			name:     "Model and provider",
+			args:     []string{"test", "-p", "add logging", "--model", "claude-sonnet-4-5", "--provider", "anthropic"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Model: "claude-sonnet-4-5", Provider: "anthropic"},
+		},
+		{
+			name:     "Force",
+			args:     []string{"test", "-p", "add logging", "--force"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Force: true},
+		},
+		{
+			name:     "Queue",
+			args:     []string{"test", "-p", "add logging", "--queue"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Queue: true},
+		},
+		{
+			name:       "Queue with base package",
+			args:       []string{"test", "-p", "add logging", "--queue", "--from", "a1b2c3d"},
+			shouldFail: true,
+		},
+		{
+			name:     "Task",
+			args:     []string{"test", "-p", "add logging", "--task", "--no-wait"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Task: true, NoWait: true},
+		},
+		{
+			name:       "Task with queue",
+			args:       []string{"test", "-p", "add logging", "--task", "--queue"},
+			shouldFail: true,
+		},
+		{
+			name:       "Task with force",
+			args:       []string{"test", "-p", "add logging", "--task", "--force"},
+			shouldFail: true,
+		},
+		{
+			name:     "Prompt file",
+			args:     []string{"test", "-f", "prompt.md"},
+			expected: PromptOptions{App: "test", File: "prompt.md"},
+		},
+		{
+			name:     "Prompt from stdin",
+			args:     []string{"test", "-p", "-"},
+			expected: PromptOptions{App: "test", Prompt: "-"},
+		},
+		{
+			name:     "Batch file without app",
+			args:     []string{"--file", "prompts.yml"},
+			expected: PromptOptions{File: "prompts.yml"},
+		},
+		{
+			name:       "Prompt file without app",
+			args:       []string{"-f", "prompt.md"},
+			shouldFail: true,
+		},
+		{
+			name:       "Prompt text and file",
+			args:       []string{"test", "-p", "add logging", "-f", "prompt.md"},
+			shouldFail: true,
+		},
+		{
+			name:     "Variants",
+			args:     []string{"test", "-p", "add logging", "--variants", "3"},
+			expected: PromptOptions{App: "test", Prompt: "add logging", Variants: 3},
+		},
+		{
+			name:       "Variants without waiting",
+			args:       []string{"test", "-p", "add logging", "--variants", "3", "--no-wait"},
+			shouldFail: true,
+		},
+		{
+			name:       "Invalid variants",
+			args:       []string{"test", "-p", "add logging", "--variants", "none"},
+			shouldFail: true,
+		},
+		{
+			name:     "Template with variables",
+			args:     []string{"test", "--template", "upgrade", "--var", "name=gin", "--var", "version=1.10.0"},
+			expected: PromptOptions{App: "test", Template: "upgrade", Vars: map[string]string{"name": "gin", "version": "1.10.0"}},
+		},
+		{
+			name:       "Template and prompt text",
+			args:       []string{"test", "--template", "upgrade", "-p", "add logging"},
+			shouldFail: true,
+		},
+		{
+			name:       "Variable without template",
+			args:       []string{"test", "-p", "add logging", "--var", "name=gin"},
+			shouldFail: true,
+		},
+		{
+			name:       "Malformed variable",
+			args:       []string{"test", "--template", "upgrade", "--var", "gin"},
+			shouldFail: true,
+		},
+		{
+			name:       "Provider without model",
+			args:       []string{"test", "-p", "add logging", "--provider", "anthropic"},
+			shouldFail: true,
+		},
+		{
+			name:       "Unknown agent",
+			args:       []string{"test", "-p", "add logging", "--agent", "unknown"},
+			shouldFail: true,
+		},
+		{
+			name:       "Command agent without command",
+			args:       []string{"test", "-p", "add logging", "--agent", "command"},
+			shouldFail: true,
+		},
+		{
+			name:       "Conflicting base packages",
+			args:       []string{"test", "-p", "add logging", "--from", "a1b2c3d", "--from-current"},
+			shouldFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, failed := ParsePromptOptions(tt.args)
+
+			if tt.shouldFail != failed {
+				t.Fatalf("Expected failed=%v, got %v", tt.shouldFail, failed)
+			}
+			if !tt.shouldFail && !reflect.DeepEqual(opts, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
 func TestSimpleTable(t *testing.T) {
 	// Capture stdout
 	oldStdout := os.Stdout
@@ -121,3 +305,169 @@ func TestSimpleTable(t *testing.T) {
 		t.Error("Table should contain 'Fix bug' prompt")
 	}
 }
+
+func TestPromptsArgumentParsing(t *testing.T) {
+	opts, failed := ParsePromptsArgs([]string{"test", "--graph"})
+	if failed {
+		t.Fatal("Expected parsing to succeed, but it failed")
+	}
+	if opts.App != "test" || !opts.Graph {
+		t.Errorf("Expected app 'test' with graph, got %+v", opts)
+	}
+
+	if _, failed := ParsePromptsArgs([]string{"--graph"}); !failed {
+		t.Error("Expected parsing to fail without an app name")
+	}
+
+	opts, failed = ParsePromptsArgs([]string{"test", "--output", "json"})
+	if failed || opts.Output != "json" {
+		t.Errorf("Expected json output, got %+v (failed=%v)", opts, failed)
+	}
+
+	for _, args := range [][]string{{"test", "-o", "xml"}, {"test", "-o", "yaml", "--graph"}} {
+		if _, failed := ParsePromptsArgs(args); !failed {
+			t.Errorf("Expected parsing of %v to fail", args)
+		}
+	}
+}
+
+func TestStructuredOutput(t *testing.T) {
+	infos := []packageInfo{{
+		GUID:        "0b7a0d6e-5c3c-4c8f-9d5e-1f2a3b4c5d6e",
+		Hash:        "a1b2c3d",
+		State:       "ready",
+		Prompt:      "add a /health endpoint that returns 200 OK and the current build version",
+		Current:     true,
+		Annotations: map[string]string{"cf-prompt-cli-plugin/parent-hash": "e4f5a6b"},
+	}}
+
+	var buf bytes.Buffer
+	if err := writeStructuredOutput(&buf, "json", infos); err != nil {
+		t.Fatalf("Failed to write json: %v", err)
+	}
+	for _, fragment := range []string{`"hash": "a1b2c3d"`, `"current": true`, `"prompt": "add a /health endpoint that returns 200 OK and the current build version"`, `"cf-prompt-cli-plugin/parent-hash": "e4f5a6b"`} {
+		if !strings.Contains(buf.String(), fragment) {
+			t.Errorf("JSON output should contain %s, got:\n%s", fragment, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := writeStructuredOutput(&buf, "yaml", infos); err != nil {
+		t.Fatalf("Failed to write yaml: %v", err)
+	}
+	if !strings.Contains(buf.String(), "- guid: 0b7a0d6e-5c3c-4c8f-9d5e-1f2a3b4c5d6e") {
+		t.Errorf("Unexpected YAML output:\n%s", buf.String())
+	}
+}
+
+func TestRenderGraph(t *testing.T) {
+	// Newest first, as returned by the API
+	nodes := []graphNode{
+		{guid: "d", parent: "b", label: "d"},
+		{guid: "c", parent: "a", label: "c"},
+		{guid: "b", parent: "a", label: "b"},
+		{guid: "a", label: "a"},
+		{guid: "x", parent: "deleted", label: "x"},
+	}
+
+	expected := []string{
+		"x",
+		"a",
+		"├─ b",
+		"│  └─ d",
+		"└─ c",
+	}
+
+	lines := renderGraph(nodes)
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expected), len(lines), strings.Join(lines, "\n"))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d: expected %q, got %q", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	summary := &events.Summary{
+		Phase:              events.PhaseUpload,
+		Agent:              "opencode 0.14.3",
+		PackageGUID:        "0b7a0d6e-5c3c-4c8f-9d5e-1f2a3b4c5d6e",
+		Validated:          true,
+		ValidationPassed:   true,
+		ValidationAttempts: 1,
+		Completed:          true,
+	}
+
+	var buf bytes.Buffer
+	writeRunSummary(&buf, "test", summary)
+	output := buf.String()
+	hash := cfclient.ShortHash(summary.PackageGUID)
+
+	for _, fragment := range []string{
+		"Prompt run succeeded",
+		"package:     " + hash,
+		"agent:       opencode 0.14.3",
+		"validation:  passed after 1 fix-up attempt\n",
+		"cf prompt-push test " + hash,
+	} {
+		if !strings.Contains(output, fragment) {
+			t.Errorf("Summary should contain %q, got:\n%s", fragment, output)
+		}
+	}
+
+	failed := &events.Summary{Phase: events.PhaseValidate, Error: "validation failed"}
+
+	buf.Reset()
+	writeRunSummary(&buf, "test", failed)
+	if !strings.Contains(buf.String(), "Prompt run failed") || !strings.Contains(buf.String(), "failed in:   validate") {
+		t.Errorf("Unexpected failure summary:\n%s", buf.String())
+	}
+}
+
+func TestGroupVariants(t *testing.T) {
+	newPackage := func(guid, group, index string) *resource.Package {
+		pkg := &resource.Package{}
+		pkg.GUID = guid
+		pkg.Metadata = &resource.Metadata{Annotations: map[string]*string{}}
+		if group != "" {
+			pkg.Metadata.Annotations[cfclient.AnnotationPrefix+"/"+cfclient.AnnotationVariantGroup] = &group
+			pkg.Metadata.Annotations[cfclient.AnnotationPrefix+"/"+cfclient.AnnotationVariantIndex] = &index
+		}
+		return pkg
+	}
+
+	// Newest first, as listed by 'cf prompts'
+	packages := []*resource.Package{
+		newPackage("latest", "", ""),
+		newPackage("a-3", "a", "3"),
+		newPackage("b-2", "b", "2"),
+		newPackage("a-2", "a", "2"),
+		newPackage("between", "", ""),
+		newPackage("a-1", "a", "1"),
+		newPackage("b-1", "b", "1"),
+		newPackage("base", "", ""),
+	}
+
+	var order []string
+	for _, pkg := range groupVariants(packages) {
+		order = append(order, pkg.GUID)
+	}
+	expected := []string{"latest", "a-1", "a-2", "a-3", "b-1", "b-2", "between", "base"}
+	if strings.Join(order, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+
+	if label := variantLabel(packages[1]); label != "a#3" {
+		t.Errorf("Expected label a#3, got %q", label)
+	}
+	if label := variantLabel(packages[0]); label != "" {
+		t.Errorf("Expected no label for a regular package, got %q", label)
+	}
+
+	variants := findVariants(packages, "b")
+	if len(variants) != 2 || variants[0].GUID != "b-1" || variants[1].GUID != "b-2" {
+		t.Errorf("Unexpected variants of group b: %v", variants)
+	}
+}
@@ -26,6 +26,24 @@ func (p *PromptPlugin) Run(cliConnection plugin.CliConnection, args []string) {
 		cmd.PromptPushCommand(cliConnection, args[1:])
 	case "prompt-init":
 		cmd.PromptInitCommand(cliConnection, args[1:])
+	case "prompt-diff":
+		cmd.PromptDiffCommand(cliConnection, args[1:])
+	case "prompt-show":
+		cmd.PromptShowCommand(cliConnection, args[1:])
+	case "prompt-rollback":
+		cmd.PromptRollbackCommand(cliConnection, args[1:])
+	case "prompt-status":
+		cmd.PromptStatusCommand(cliConnection, args[1:])
+	case "prompt-attach":
+		cmd.PromptAttachCommand(cliConnection, args[1:])
+	case "prompt-cancel":
+		cmd.PromptCancelCommand(cliConnection, args[1:])
+	case "prompt-queue":
+		cmd.PromptQueueCommand(cliConnection, args[1:])
+	case "prompt-template":
+		cmd.PromptTemplateCommand(cliConnection, args[1:])
+	case "prompt-doctor":
+		cmd.PromptDoctorCommand(cliConnection, args[1:])
 	default:
 		fmt.Printf("Error: Unknown command '%s'\n", args[0])
 		os.Exit(1)
@@ -50,10 +68,27 @@ func (p *PromptPlugin) GetMetadata() plugin.PluginMetadata {
 				Name:     "prompt",
 				HelpText: "Execute a natural language prompt as a CF task",
 				UsageDetails: plugin.Usage{
-					Usage: "cf prompt APP_NAME -p 'prompt text'",
+					Usage: "cf prompt APP_NAME -p 'prompt text' [--from PACKAGE_HASH | --from-current | --continue PACKAGE_HASH]",
 					Options: map[string]string{
-						"-a, --app":    "Target application name",
-						"-p, --prompt": "Prompt text to execute",
+						"-a, --app":           "Target application name",
+						"-p, --prompt":        "Prompt text to execute, '-' reads it from stdin",
+						"-f, --file":          "File to read the prompt from, or a YAML batch of prompts",
+						"--template":          "Saved prompt template to render as the prompt",
+						"--var":               "Value of a template variable as NAME=VALUE, may be repeated",
+						"--from":              "Start from the package with this hash instead of the latest package",
+						"--from-current":      "Start from the package of the currently deployed droplet",
+						"--continue":          "Continue the conversation that produced the package with this hash",
+						"--validate":          "Command that must succeed on the changed source, e.g. 'go test ./...'",
+						"--validate-attempts": "Number of times the agent may try to fix a failed validation (default 3)",
+						"--agent":             "Coding agent to run the prompt with: opencode (default), aider or command",
+						"--agent-command":     "Shell command run by the command agent, the prompt is passed in $PROMPT",
+						"--model":             "Model used by the agent, e.g. claude-sonnet-4-5",
+						"--provider":          "Provider of the model, e.g. anthropic",
+						"--no-wait":           "Start the run in the background and return its run ID",
+						"--force":             "Break the lock of another run that is still holding the prompter",
+						"--queue":             "Queue the prompt if another run is active, it then starts from that run's package",
+						"--task":              "Run the prompt as a CF task on the prompter's droplet, runs may then be parallel",
+						"--variants":          "Number of alternative packages to create for the prompt from the same base",
 					},
 				},
 			},
@@ -61,7 +96,11 @@ func (p *PromptPlugin) GetMetadata() plugin.PluginMetadata {
 				Name:     "prompts",
 				HelpText: "List packages for an app with their status and original prompts",
 				UsageDetails: plugin.Usage{
-					Usage: "cf prompts <APP_NAME>",
+					Usage: "cf prompts <APP_NAME> [--graph | --output json|yaml]",
+					Options: map[string]string{
+						"--graph":      "Show packages as a tree of the revisions they were created from",
+						"-o, --output": "Output format for scripting: json or yaml",
+					},
 				},
 			},
 			{
@@ -75,7 +114,100 @@ func (p *PromptPlugin) GetMetadata() plugin.PluginMetadata {
 				Name:     "prompt-init",
 				HelpText: "Initialize prompter app for an application (one-time setup)",
 				UsageDetails: plugin.Usage{
-					Usage: "cf prompt-init <APP_NAME>",
+					Usage: "cf prompt-init <APP_NAME> | --shared [--config FILE] [--memory SIZE] [--disk SIZE] [--buildpack BUILDPACK] [--stack STACK] [--env NAME=VALUE] [--service SERVICE] [--docker-image IMAGE]",
+					Options: map[string]string{
+						"--shared":          "Deploy a single prompter for every app in the space without a prompter of its own",
+						"--upgrade":         "Stage this plugin's prompter in the existing prompter app if it runs another version, keeping its configuration",
+						"--config":          "YAML file with the settings below, flags take precedence",
+						"--memory":          "Memory of the prompter (default 1G)",
+						"--disk":            "Disk quota of the prompter (default 2G)",
+						"--buildpack":       "Buildpack to stage the prompter with (default paketo-buildpacks/procfile)",
+						"--stack":           "Stack to stage the prompter on",
+						"--env":             "Environment variable of the prompter as NAME=VALUE, may be repeated",
+						"--service":         "Service instance to bind to the prompter, may be repeated",
+						"--docker-image":    "Run the prompter from a Docker image instead of staging it",
+						"--docker-username": "User for a private Docker registry, the password is read from CF_DOCKER_PASSWORD",
+					},
+				},
+			},
+			{
+				Name:     "prompt-diff",
+				HelpText: "Show the source diff between two package revisions",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-diff <APP_NAME> <PACKAGE_HASH> [OTHER_PACKAGE_HASH] | --variants GROUP",
+					Options: map[string]string{
+						"--stat":      "Show a summary of changed files",
+						"--name-only": "Show only the names of changed files",
+						"--variants":  "Compare each package of a variant group to its base package",
+					},
+				},
+			},
+			{
+				Name:     "prompt-show",
+				HelpText: "Show the details of a single package revision",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-show <APP_NAME> <PACKAGE_HASH> [--files] [--diff]",
+					Options: map[string]string{
+						"--files": "Show all files in the package as a tree",
+						"--diff":  "Show the diff against the package it was created from",
+					},
+				},
+			},
+			{
+				Name:     "prompt-rollback",
+				HelpText: "Restore the droplet that was current before an earlier prompt-push",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-rollback <APP_NAME> [--steps N] [--restart]",
+					Options: map[string]string{
+						"--steps":   "Number of pushes to roll back (default 1)",
+						"--restart": "Restart the app after restoring the droplet",
+					},
+				},
+			},
+			{
+				Name:     "prompt-status",
+				HelpText: "Show the phase and progress of a prompt run",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-status <APP_NAME> [RUN_ID]",
+				},
+			},
+			{
+				Name:     "prompt-attach",
+				HelpText: "Follow the logs of a running prompt run until it ends",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-attach <APP_NAME>",
+				},
+			},
+			{
+				Name:     "prompt-cancel",
+				HelpText: "Cancel the prompt run in progress and clean up after it",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-cancel <APP_NAME>",
+				},
+			},
+			{
+				Name:     "prompt-queue",
+				HelpText: "List or remove prompts queued with 'cf prompt --queue'",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-queue <APP_NAME> [list | remove ID]",
+				},
+			},
+			{
+				Name:     "prompt-template",
+				HelpText: "Save, list, show and delete reusable prompt templates",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-template list | show NAME | save NAME (-p 'prompt text' | -f FILE) | delete NAME",
+					Options: map[string]string{
+						"-p, --prompt": "Template text, variables are written as {{name}}",
+						"-f, --file":   "File to read the template from",
+					},
+				},
+			},
+			{
+				Name:     "prompt-doctor",
+				HelpText: "Check the CF API, registry, prompter, OpenCode download and provider credentials for an app",
+				UsageDetails: plugin.Usage{
+					Usage: "cf prompt-doctor <APP_NAME>",
 				},
 			},
 		},
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DefaultName is the agent used when none is selected
+const DefaultName = "opencode"
+
+// Agent is a coding agent CLI that applies a natural language prompt to the source code in a directory
+type Agent interface {
+	// Name returns the name the agent is selected by
+	Name() string
+	// Version returns the version of the agent that is installed by EnsureInstalled
+	Version() string
+	// EnsureInstalled installs the agent if it is not available yet
+	EnsureInstalled() error
+	// Run applies the prompt to the source code in workDir
+	Run(ctx context.Context, workDir, prompt string, stdout io.Writer) error
+}
+
+// Options holds backend specific settings
+type Options struct {
+	// Command is the shell command run by the "command" agent
+	Command string
+	// Model and Provider select the language model, the agent's defaults are used when empty
+	Model    string
+	Provider string
+}
+
+// QualifiedModel returns the model in provider/model notation, or just the model if no provider is set
+func (o Options) QualifiedModel() string {
+	if o.Model == "" || o.Provider == "" {
+		return o.Model
+	}
+	return o.Provider + "/" + o.Model
+}
+
+var constructors = map[string]func(Options) (Agent, error){
+	"opencode": newOpenCode,
+	"aider":    newAider,
+	"command":  newCommand,
+}
+
+// New returns the agent with the given name, or the default agent if name is empty
+func New(name string, opts Options) (Agent, error) {
+	if name == "" {
+		name = DefaultName
+	}
+
+	constructor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent '%s', available agents: %v", name, Names())
+	}
+
+	return constructor(opts)
+}
+
+// Names returns the names of all available agents
+func Names() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const AiderVersion = "0.86.1"
+
+// Aider runs prompts with 'aider --message'
+type Aider struct {
+	binaryPath string
+	model      string
+	// version is the version reported by the installed binary
+	version string
+}
+
+func newAider(opts Options) (Agent, error) {
+	return &Aider{model: opts.QualifiedModel()}, nil
+}
+
+func (a *Aider) Name() string {
+	return "aider"
+}
+
+// Version returns the version of the aider binary that runs the prompt, which may differ
+// from AiderVersion if aider was already installed
+func (a *Aider) Version() string {
+	if a.version != "" {
+		return a.version
+	}
+	return AiderVersion
+}
+
+// EnsureInstalled installs aider with pip into the user's home directory unless it is already on the PATH
+func (a *Aider) EnsureInstalled() error {
+	if err := a.install(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(a.binaryPath, "--version")
+	cmd.Env = Environ()
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to get aider version: %w", err)
+	}
+	a.version = parseAiderVersion(string(output))
+	return nil
+}
+
+// parseAiderVersion returns the version in the output of 'aider --version', e.g. "aider 0.86.1"
+func parseAiderVersion(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(fields[len(fields)-1], "v")
+}
+
+func (a *Aider) install() error {
+	if path, err := exec.LookPath("aider"); err == nil {
+		a.binaryPath = path
+		return nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+	binaryPath := filepath.Join(homeDir, ".local", "bin", "aider")
+
+	if _, err := os.Stat(binaryPath); err == nil {
+		a.binaryPath = binaryPath
+		return nil
+	}
+
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		return fmt.Errorf("aider requires python3, which was not found on the PATH")
+	}
+
+	fmt.Printf("Installing aider version %s...\n", AiderVersion)
+
+	cmd := exec.Command(python, "-m", "pip", "install", "--user", fmt.Sprintf("aider-chat==%s", AiderVersion))
+	cmd.Env = Environ()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to install aider: %w", err)
+	}
+
+	a.binaryPath = binaryPath
+	fmt.Println("aider installed successfully")
+	return nil
+}
+
+func (a *Aider) Run(ctx context.Context, workDir, prompt string, stdout io.Writer) error {
+	binaryPath := a.binaryPath
+	if binaryPath == "" {
+		binaryPath = "aider"
+	}
+
+	args := []string{"--yes-always", "--no-git", "--no-auto-commits", "--no-pretty"}
+	if a.model != "" {
+		args = append(args, "--model", a.model)
+	}
+	args = append(args, "--message", prompt)
+
+	cmd := exec.CommandContext(ctx, binaryPath, args...)
+	cmd.Dir = workDir
+	cmd.Env = Environ()
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("aider run failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package agent
+
+import "testing"
+
+func TestParseAiderVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{name: "Name and version", output: "aider 0.86.1\n", expected: "0.86.1"},
+		{name: "Prefixed version", output: "aider v0.82.0", expected: "0.82.0"},
+		{name: "Empty output", output: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if version := parseAiderVersion(tt.output); version != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, version)
+			}
+		})
+	}
+}
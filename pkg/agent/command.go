@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Command runs an arbitrary shell command as agent. The prompt is passed in the
+// PROMPT environment variable and the selected model, if any, in MODEL and
+// PROVIDER, so any CLI agent can be wrapped, for example:
+//
+//	mytool --non-interactive --message "$PROMPT"
+type Command struct {
+	command  string
+	model    string
+	provider string
+}
+
+func newCommand(opts Options) (Agent, error) {
+	if opts.Command == "" {
+		return nil, fmt.Errorf("the command agent requires a command")
+	}
+	return &Command{command: opts.Command, model: opts.Model, provider: opts.Provider}, nil
+}
+
+func (a *Command) Name() string {
+	return "command"
+}
+
+func (a *Command) Version() string {
+	return "custom"
+}
+
+// EnsureInstalled does nothing, the command is expected to be available in the prompter container
+func (a *Command) EnsureInstalled() error {
+	return nil
+}
+
+func (a *Command) Run(ctx context.Context, workDir, prompt string, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.command)
+	cmd.Dir = workDir
+	cmd.Env = append(Environ(), "PROMPT="+prompt, "MODEL="+a.model, "PROVIDER="+a.provider)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("agent command failed: %w", err)
+	}
+
+	return nil
+}
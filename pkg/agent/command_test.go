@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommandEnvironment(t *testing.T) {
+	t.Setenv("VCAP_SERVICES", `{"user-provided":[{"name":"app-prompter-run-1","tags":["cf-prompt-run"],"credentials":{"run_id":"1","CF_ACCESS_TOKEN":"token-1"}}]}`)
+	t.Setenv("CF_ACCESS_TOKEN", "token-1")
+	t.Setenv("REGISTRY_PASSWORD", "secret")
+	t.Setenv("ANTHROPIC_API_KEY", "provider-key")
+
+	a, err := New("command", Options{Command: "env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := a.Run(context.Background(), t.TempDir(), "add logging", &stdout); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	env := stdout.String()
+	for _, secret := range []string{"VCAP_SERVICES=", "cf-prompt-run", "token-1", "REGISTRY_PASSWORD="} {
+		if strings.Contains(env, secret) {
+			t.Errorf("Expected %q not to be visible to the agent", secret)
+		}
+	}
+	for _, expected := range []string{"ANTHROPIC_API_KEY=provider-key", "PROMPT=add logging"} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("Expected %q in the environment of the agent", expected)
+		}
+	}
+}
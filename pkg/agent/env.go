@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"os"
+	"strings"
+)
+
+// secretVariables are removed from the environment of the processes the prompter
+// starts. VCAP_SERVICES holds the run credentials service, the others are set by
+// earlier versions of the plugin.
+var secretVariables = []string{"VCAP_SERVICES", "CF_ACCESS_TOKEN", "REGISTRY_USERNAME", "REGISTRY_PASSWORD", "PROMPT_BASE64"}
+
+// Environ returns the environment for agents and validation commands: the environment
+// of the prompter, including the exported provider credentials, without the secrets of
+// the run
+func Environ() []string {
+	env := make([]string, 0, len(os.Environ()))
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		if !isSecretVariable(key) {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+func isSecretVariable(key string) bool {
+	for _, secret := range secretVariables {
+		if key == secret {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"context"
+	"io"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/opencode"
+)
+
+// OpenCode runs prompts with 'opencode run'
+type OpenCode struct {
+	model string
+}
+
+func newOpenCode(opts Options) (Agent, error) {
+	return &OpenCode{model: opts.QualifiedModel()}, nil
+}
+
+func (a *OpenCode) Name() string {
+	return "opencode"
+}
+
+func (a *OpenCode) Version() string {
+	return opencode.OpencodeVersion
+}
+
+func (a *OpenCode) EnsureInstalled() error {
+	return opencode.EnsureInstalled()
+}
+
+func (a *OpenCode) Run(ctx context.Context, workDir, prompt string, stdout io.Writer) error {
+	return opencode.Run(ctx, workDir, prompt, a.model, Environ(), stdout)
+}
@@ -0,0 +1,170 @@
+package cfclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// AnnotationPrefix is the metadata prefix used for all annotations written by the plugin
+const AnnotationPrefix = "cf-prompt-cli-plugin"
+
+const (
+	AnnotationOriginalPrompt = "original-prompt"
+	AnnotationParentPackage  = "parent-package"
+	AnnotationParentHash     = "parent-hash"
+	// AnnotationContinues marks a package whose prompt was a follow-up to the
+	// prompts of its parent package, linking them into one conversation
+	AnnotationContinues = "continues"
+	// AnnotationValidatedWith holds the validation command the package source passed
+	AnnotationValidatedWith = "validated-with"
+	// AnnotationAgent holds the name and version of the coding agent that created the package
+	AnnotationAgent = "agent"
+	// AnnotationRunID links a package to the prompter run that created it
+	AnnotationRunID = "run-id"
+	// AnnotationModel holds the model the package was created with, in provider/model notation
+	AnnotationModel = "model"
+	// AnnotationVariantGroup and AnnotationVariantIndex mark the packages created as
+	// alternatives for the same prompt with 'cf prompt --variants', the index starts at 1
+	AnnotationVariantGroup = "variant-group"
+	AnnotationVariantIndex = "variant-index"
+	// AnnotationTemplate and AnnotationTemplateVars hold the name of the prompt template the
+	// prompt was rendered from and its variables as a JSON object
+	AnnotationTemplate     = "template"
+	AnnotationTemplateVars = "template-vars"
+)
+
+// App labels
+const (
+	// LabelPrompterVersion holds the version of the prompter deployed in a prompter app
+	LabelPrompterVersion = "prompter-version"
+)
+
+// App annotations
+const (
+	// AnnotationDropletHistory holds the previously current droplet GUIDs of an app,
+	// most recent first, so prompt-rollback can restore them
+	AnnotationDropletHistory = "droplet-history"
+)
+
+// GetAnnotation returns the value of a plugin annotation (key without prefix) on a package
+func GetAnnotation(pkg *resource.Package, key string) (string, bool) {
+	if pkg.Metadata != nil && pkg.Metadata.Annotations != nil {
+		if value, exists := pkg.Metadata.Annotations[fmt.Sprintf("%s/%s", AnnotationPrefix, key)]; exists && value != nil {
+			return *value, true
+		}
+	}
+	return "", false
+}
+
+// GetPluginAnnotations returns all plugin annotations on a package keyed by their full name
+func GetPluginAnnotations(pkg *resource.Package) map[string]string {
+	annotations := make(map[string]string)
+	if pkg.Metadata == nil {
+		return annotations
+	}
+
+	for key, value := range pkg.Metadata.Annotations {
+		if value != nil && strings.HasPrefix(key, AnnotationPrefix+"/") {
+			annotations[key] = *value
+		}
+	}
+	return annotations
+}
+
+// GetAppAnnotation returns the value of a plugin annotation (key without prefix) on an app
+func (c *Client) GetAppAnnotation(appGUID, key string) (string, bool, error) {
+	app, err := c.GetApp(appGUID)
+	if err != nil {
+		return "", false, err
+	}
+
+	if app.Metadata != nil && app.Metadata.Annotations != nil {
+		if value, exists := app.Metadata.Annotations[fmt.Sprintf("%s/%s", AnnotationPrefix, key)]; exists && value != nil {
+			return *value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// GetAppAnnotations returns all plugin annotations on an app keyed without prefix
+func (c *Client) GetAppAnnotations(appGUID string) (map[string]string, error) {
+	app, err := c.GetApp(appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := make(map[string]string)
+	if app.Metadata == nil {
+		return annotations, nil
+	}
+
+	for key, value := range app.Metadata.Annotations {
+		if value != nil && strings.HasPrefix(key, AnnotationPrefix+"/") {
+			annotations[strings.TrimPrefix(key, AnnotationPrefix+"/")] = *value
+		}
+	}
+	return annotations, nil
+}
+
+// GetAppLabel returns the value of a plugin label (key without prefix) on an app
+func (c *Client) GetAppLabel(appGUID, key string) (string, bool, error) {
+	app, err := c.GetApp(appGUID)
+	if err != nil {
+		return "", false, err
+	}
+
+	if app.Metadata != nil && app.Metadata.Labels != nil {
+		if value, exists := app.Metadata.Labels[fmt.Sprintf("%s/%s", AnnotationPrefix, key)]; exists && value != nil {
+			return *value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// UpdateAppLabels sets plugin labels (keys without prefix) on an app. Empty values remove the label.
+func (c *Client) UpdateAppLabels(appGUID string, labels map[string]string) error {
+	return c.updateAppMetadata(appGUID, "labels", labels)
+}
+
+// UpdateAppAnnotations sets plugin annotations (keys without prefix) on an app. Empty values remove the annotation.
+func (c *Client) UpdateAppAnnotations(appGUID string, annotations map[string]string) error {
+	return c.updateAppMetadata(appGUID, "annotations", annotations)
+}
+
+// updateAppMetadata sets plugin labels or annotations, depending on kind, on an app
+func (c *Client) updateAppMetadata(appGUID, kind string, metadata map[string]string) error {
+	// An app update always carries the name, which must not change
+	app, err := c.GetApp(appGUID)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		fullKey := fmt.Sprintf("%s/%s", AnnotationPrefix, key)
+		if value == "" {
+			values[fullKey] = nil
+		} else {
+			v := value
+			values[fullKey] = &v
+		}
+	}
+
+	update := &resource.Metadata{
+		Labels:      map[string]*string{},
+		Annotations: map[string]*string{},
+	}
+	if kind == "labels" {
+		update.Labels = values
+	} else {
+		update.Annotations = values
+	}
+
+	if _, err := c.cf.Applications.Update(context.Background(), appGUID, &resource.AppUpdate{Name: app.Name, Metadata: update}); err != nil {
+		return fmt.Errorf("failed to update app %s: %w", kind, err)
+	}
+	return nil
+}
@@ -26,9 +26,11 @@ import (
 )
 
 type Client struct {
-	cf     *client.Client
-	apiURL string
-	token  string
+	cf               *client.Client
+	apiURL           string
+	token            string
+	registryUsername string
+	registryPassword string
 }
 
 func New(apiURL, token string) (*Client, error) {
@@ -42,6 +44,12 @@ func New(apiURL, token string) (*Client, error) {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
+	// Direct API requests send the token as Authorization header, which needs the
+	// "bearer " prefix the CF CLI includes but the prompter environment does not
+	if !strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = "bearer " + token
+	}
+
 	return &Client{
 		cf:     cf,
 		apiURL: apiURL,
@@ -49,6 +57,22 @@ func New(apiURL, token string) (*Client, error) {
 	}, nil
 }
 
+// Ping makes sure the CF API answers at the configured endpoint
+func (c *Client) Ping() error {
+	var root map[string]interface{}
+	if err := c.getJSON(c.apiURL+"/", &root); err != nil {
+		return fmt.Errorf("failed to reach CF API at %s: %w", c.apiURL, err)
+	}
+	return nil
+}
+
+// SetRegistryCredentials configures the credentials used to pull image-based packages.
+// When not set, REGISTRY_USERNAME and REGISTRY_PASSWORD from the environment are used.
+func (c *Client) SetRegistryCredentials(username, password string) {
+	c.registryUsername = username
+	c.registryPassword = password
+}
+
 func (c *Client) GetAppGUID(appName, spaceGUID string) (string, error) {
 	opts := client.NewAppListOptions()
 	opts.SpaceGUIDs = client.Filter{Values: []string{spaceGUID}}
@@ -60,12 +84,21 @@ func (c *Client) GetAppGUID(appName, spaceGUID string) (string, error) {
 	}
 
 	if len(apps) == 0 {
-		return "", fmt.Errorf("app '%s' not found", appName)
+		return "", &AppNotFoundError{Name: appName}
 	}
 
 	return apps[0].GUID, nil
 }
 
+// AppNotFoundError is returned by GetAppGUID when no app in the space has the name
+type AppNotFoundError struct {
+	Name string
+}
+
+func (e *AppNotFoundError) Error() string {
+	return fmt.Sprintf("app '%s' not found", e.Name)
+}
+
 func (c *Client) GetLatestPackage(appGUID string) (*resource.Package, error) {
 	opts := client.NewPackageListOptions()
 	opts.AppGUIDs = client.Filter{Values: []string{appGUID}}
@@ -84,16 +117,18 @@ func (c *Client) GetLatestPackage(appGUID string) (*resource.Package, error) {
 	return packages[0], nil
 }
 
+func (c *Client) GetPackage(packageGUID string) (*resource.Package, error) {
+	pkg, err := c.cf.Packages.Get(context.Background(), packageGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	return pkg, nil
+}
+
 func (c *Client) DownloadPackage(pkg *resource.Package, destDir string) error {
 	// Check if this is an image-based package (Korifi)
-	if pkg.Data.Docker != nil || (pkg.Data.Bits != nil && pkg.DataRaw != nil) {
-		// Parse the raw data to check for image field
-		var data map[string]interface{}
-		if err := json.Unmarshal(pkg.DataRaw, &data); err == nil {
-			if imageURL, exists := data["image"]; exists {
-				return c.downloadFromImage(imageURL.(string), destDir)
-			}
-		}
+	if imageURL, ok := PackageImage(pkg); ok {
+		return c.downloadFromImage(imageURL, destDir)
 	}
 
 	// Fallback to traditional download
@@ -131,6 +166,36 @@ func (c *Client) DownloadPackage(pkg *resource.Package, destDir string) error {
 	return c.unzip(zipFile, destDir)
 }
 
+// PackageImage returns the image reference of an image-based package, as created by
+// Korifi. ok is false for packages whose bits are stored by CF.
+func PackageImage(pkg *resource.Package) (imageURL string, ok bool) {
+	if pkg.Data.Docker == nil && (pkg.Data.Bits == nil || pkg.DataRaw == nil) {
+		return "", false
+	}
+
+	// Parse the raw data to check for image field
+	var data map[string]interface{}
+	if err := json.Unmarshal(pkg.DataRaw, &data); err != nil {
+		return "", false
+	}
+	imageURL, ok = data["image"].(string)
+	return imageURL, ok
+}
+
+// DownloadPackageSource downloads a package into destDir and returns the directory containing the app source
+func (c *Client) DownloadPackageSource(pkg *resource.Package, destDir string) (string, error) {
+	if err := c.DownloadPackage(pkg, destDir); err != nil {
+		return "", err
+	}
+
+	sourceDir := filepath.Join(destDir, "app")
+	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
+		sourceDir = destDir
+	}
+
+	return sourceDir, nil
+}
+
 func (c *Client) unzip(src, dest string) error {
 	r, err := zip.OpenReader(src)
 	if err != nil {
@@ -179,6 +244,16 @@ func (c *Client) CreatePackage(appGUID, sourceDir string) (*resource.Package, er
 }
 
 func (c *Client) CreatePackageWithPrompt(appGUID, sourceDir string, prompt string) (*resource.Package, error) {
+	annotations := map[string]string{}
+	if prompt != "" {
+		annotations[AnnotationOriginalPrompt] = prompt
+	}
+	return c.CreatePackageWithAnnotations(appGUID, sourceDir, annotations)
+}
+
+// CreatePackageWithAnnotations creates and uploads a package, storing the given
+// annotations (keys without the plugin prefix) in its metadata
+func (c *Client) CreatePackageWithAnnotations(appGUID, sourceDir string, annotations map[string]string) (*resource.Package, error) {
 	entries, err := os.ReadDir(sourceDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read source directory: %w", err)
@@ -194,9 +269,13 @@ func (c *Client) CreatePackageWithPrompt(appGUID, sourceDir string, prompt strin
 
 	pkgCreate := resource.NewPackageCreate(appGUID)
 
-	if prompt != "" {
+	if len(annotations) > 0 {
 		metadata := resource.NewMetadata()
-		metadata.SetAnnotation("cf-prompt-cli-plugin", "original-prompt", prompt)
+		for key, value := range annotations {
+			if value != "" {
+				metadata.SetAnnotation(AnnotationPrefix, key, value)
+			}
+		}
 		pkgCreate.Metadata = metadata
 	}
 
@@ -274,13 +353,28 @@ func (c *Client) CreatePackageWithPrompt(appGUID, sourceDir string, prompt strin
 	return pkg, nil
 }
 
-func (c *Client) GetOriginalPrompt(pkg *resource.Package) (string, bool) {
-	if pkg.Metadata != nil && pkg.Metadata.Annotations != nil {
-		if prompt, exists := pkg.Metadata.Annotations["cf-prompt-cli-plugin/original-prompt"]; exists && prompt != nil {
-			return *prompt, true
-		}
+// CreateDockerPackage creates a package for the app that runs the given Docker image.
+// The credentials are only needed for private registries.
+func (c *Client) CreateDockerPackage(appGUID, image, username, password string) (*resource.Package, error) {
+	create := resource.NewDockerPackageCreate(appGUID, image, username, password)
+	if username == "" {
+		create.Data.DockerCredentials = nil
 	}
-	return "", false
+
+	pkg, err := c.cf.Packages.Create(context.Background(), create)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker package: %w", err)
+	}
+	return pkg, nil
+}
+
+func (c *Client) GetOriginalPrompt(pkg *resource.Package) (string, bool) {
+	return GetAnnotation(pkg, AnnotationOriginalPrompt)
+}
+
+// GetParentPackageGUID returns the GUID of the package a prompt-generated package was created from
+func (c *Client) GetParentPackageGUID(pkg *resource.Package) (string, bool) {
+	return GetAnnotation(pkg, AnnotationParentPackage)
 }
 
 func (c *Client) ListPackagesWithPrompts(appGUID string) ([]*resource.Package, error) {
@@ -303,21 +397,8 @@ func (c *Client) downloadFromImage(imageURL, destDir string) error {
 		return fmt.Errorf("failed to parse image reference: %w", err)
 	}
 
-	// Create authenticator for registry
-	var auth authn.Authenticator = authn.Anonymous
-
-	// Check for registry credentials in environment
-	if username := os.Getenv("REGISTRY_USERNAME"); username != "" {
-		if password := os.Getenv("REGISTRY_PASSWORD"); password != "" {
-			auth = &authn.Basic{
-				Username: username,
-				Password: password,
-			}
-		}
-	}
-
 	// Pull the image with authentication
-	img, err := remote.Image(ref, remote.WithAuth(auth))
+	img, err := remote.Image(ref, remote.WithAuth(c.registryAuth()))
 	if err != nil {
 		return fmt.Errorf("failed to pull image: %w", err)
 	}
@@ -338,6 +419,37 @@ func (c *Client) downloadFromImage(imageURL, destDir string) error {
 	return nil
 }
 
+// CheckImageAccess makes sure the image of a package can be pulled with the registry
+// credentials, by fetching its manifest only
+func (c *Client) CheckImageAccess(imageURL string) error {
+	ref, err := name.ParseReference(imageURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse image reference: %w", err)
+	}
+
+	if _, err := remote.Head(ref, remote.WithAuth(c.registryAuth())); err != nil {
+		return fmt.Errorf("failed to access image: %w", err)
+	}
+	return nil
+}
+
+// registryAuth returns the authenticator for the registry of image-based packages
+func (c *Client) registryAuth() authn.Authenticator {
+	// Prefer explicitly configured credentials, then fall back to the environment
+	username, password := c.registryUsername, c.registryPassword
+	if username == "" || password == "" {
+		username = os.Getenv("REGISTRY_USERNAME")
+		password = os.Getenv("REGISTRY_PASSWORD")
+	}
+	if username == "" || password == "" {
+		return authn.Anonymous
+	}
+	return &authn.Basic{
+		Username: username,
+		Password: password,
+	}
+}
+
 func (c *Client) extractLayerToDir(layer v1.Layer, destDir string) error {
 	rc, err := layer.Uncompressed()
 	if err != nil {
@@ -406,6 +518,33 @@ func (c *Client) StopApp(appGUID string) error {
 	return nil
 }
 
+// GetCurrentDropletGUID returns the GUID of the app's current droplet, or an empty string if it has none
+func (c *Client) GetCurrentDropletGUID(appGUID string) (string, error) {
+	droplet, err := c.cf.Droplets.GetCurrentForApp(context.Background(), appGUID)
+	if err != nil {
+		if resource.IsResourceNotFoundError(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get current droplet: %w", err)
+	}
+	return droplet.GUID, nil
+}
+
+// GetDropletPackageGUID returns the GUID of the package a droplet was staged from
+func (c *Client) GetDropletPackageGUID(dropletGUID string) (string, error) {
+	droplet, err := c.cf.Droplets.Get(context.Background(), dropletGUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get droplet: %w", err)
+	}
+
+	if packageLink, ok := droplet.Links["package"]; ok {
+		parts := strings.Split(packageLink.Href, "/")
+		return parts[len(parts)-1], nil
+	}
+
+	return "", fmt.Errorf("droplet %s has no package", dropletGUID)
+}
+
 func (c *Client) GetCurrentDropletPackageGUID(appGUID string) (string, error) {
 	// Construct the URL for the current droplet endpoint
 	url := fmt.Sprintf("%s/v3/apps/%s/droplets/current", c.apiURL, appGUID)
@@ -569,6 +708,24 @@ func (c *Client) GetPackageDropletGUID(packageGUID string) (string, error) {
 	return result.Resources[0].GUID, nil
 }
 
+// GetPackageBuild returns the most recent build that staged the package, or nil if it was never staged
+func (c *Client) GetPackageBuild(packageGUID string) (*resource.Build, error) {
+	opts := client.NewBuildListOptions()
+	opts.PackageGUIDs = client.Filter{Values: []string{packageGUID}}
+	opts.OrderBy = "-created_at"
+
+	builds, err := c.cf.Builds.ListAll(context.Background(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+
+	if len(builds) == 0 {
+		return nil, nil
+	}
+
+	return builds[0], nil
+}
+
 func (c *Client) GetBuildStatus(buildGUID string) (string, error) {
 	url := fmt.Sprintf("%s/v3/builds/%s", c.apiURL, buildGUID)
 
@@ -854,3 +1011,37 @@ func (c *Client) SetCurrentDroplet(appGUID, dropletGUID string) error {
 
 	return nil
 }
+
+// DeletePendingPackages deletes the app's packages that are still awaiting or processing
+// their upload and were created by the given prompter run. It returns the deleted GUIDs.
+func (c *Client) DeletePendingPackages(appGUID, runID string) ([]string, error) {
+	opts := client.NewPackageListOptions()
+	opts.AppGUIDs = client.Filter{Values: []string{appGUID}}
+	opts.States = client.Filter{Values: []string{"AWAITING_UPLOAD", "PROCESSING_UPLOAD"}}
+
+	packages, err := c.cf.Packages.ListAll(context.Background(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	var deleted []string
+	for _, pkg := range packages {
+		if value, ok := GetAnnotation(pkg, AnnotationRunID); !ok || value != runID {
+			continue
+		}
+		if _, err := c.cf.Packages.Delete(context.Background(), pkg.GUID); err != nil {
+			return deleted, fmt.Errorf("failed to delete package %s: %w", pkg.GUID, err)
+		}
+		deleted = append(deleted, pkg.GUID)
+	}
+
+	return deleted, nil
+}
+
+// DeletePackage deletes a package
+func (c *Client) DeletePackage(packageGUID string) error {
+	if _, err := c.cf.Packages.Delete(context.Background(), packageGUID); err != nil {
+		return fmt.Errorf("failed to delete package: %w", err)
+	}
+	return nil
+}
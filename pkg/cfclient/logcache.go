@@ -0,0 +1,183 @@
+package cfclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEnvelope is a single log line read from Log Cache
+type LogEnvelope struct {
+	Timestamp  time.Time
+	SourceType string
+	InstanceID string
+	Message    string
+	Stderr     bool
+}
+
+// logCacheURL returns the Log Cache endpoint advertised by the API root
+func (c *Client) logCacheURL() (string, error) {
+	var root struct {
+		Links map[string]struct {
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+
+	if err := c.getJSON(c.apiURL+"/", &root); err != nil {
+		return "", fmt.Errorf("failed to get API root: %w", err)
+	}
+
+	link, ok := root.Links["log_cache"]
+	if !ok || link.Href == "" {
+		return "", fmt.Errorf("API does not advertise a log_cache endpoint")
+	}
+
+	return strings.TrimSuffix(link.Href, "/"), nil
+}
+
+// ReadLogs returns the log envelopes of an app that were emitted after since, oldest first
+func (c *Client) ReadLogs(appGUID string, since time.Time) ([]LogEnvelope, error) {
+	logCacheURL, err := c.logCacheURL()
+	if err != nil {
+		return nil, err
+	}
+	return c.readLogs(logCacheURL, appGUID, since)
+}
+
+func (c *Client) readLogs(logCacheURL, appGUID string, since time.Time) ([]LogEnvelope, error) {
+	query := url.Values{}
+	query.Set("start_time", strconv.FormatInt(since.UnixNano(), 10))
+	query.Set("envelope_types", "LOG")
+	query.Set("limit", "1000")
+
+	var result struct {
+		Envelopes struct {
+			Batch []struct {
+				Timestamp  string            `json:"timestamp"`
+				InstanceID string            `json:"instance_id"`
+				Tags       map[string]string `json:"tags"`
+				Log        *struct {
+					Payload string `json:"payload"`
+					Type    string `json:"type"`
+				} `json:"log"`
+			} `json:"batch"`
+		} `json:"envelopes"`
+	}
+
+	if err := c.getJSON(fmt.Sprintf("%s/api/v1/read/%s?%s", logCacheURL, appGUID, query.Encode()), &result); err != nil {
+		return nil, fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	var envelopes []LogEnvelope
+	for _, e := range result.Envelopes.Batch {
+		if e.Log == nil {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(e.Timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope timestamp '%s': %w", e.Timestamp, err)
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(e.Log.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode log payload: %w", err)
+		}
+
+		envelopes = append(envelopes, LogEnvelope{
+			Timestamp:  time.Unix(0, nanos),
+			SourceType: e.Tags["source_type"],
+			InstanceID: e.InstanceID,
+			Message:    strings.TrimRight(string(payload), "\n"),
+			Stderr:     e.Log.Type == "ERR",
+		})
+	}
+
+	return envelopes, nil
+}
+
+// TailLogs polls Log Cache for the app's log envelopes emitted after since and passes
+// them to handle in order until ctx is done or handle returns false
+func (c *Client) TailLogs(ctx context.Context, appGUID string, since time.Time, interval time.Duration, handle func(LogEnvelope) bool) error {
+	logCacheURL, err := c.logCacheURL()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		envelopes, err := c.readLogs(logCacheURL, appGUID, since)
+		if err != nil {
+			return err
+		}
+
+		for _, envelope := range envelopes {
+			if !envelope.Timestamp.Before(since) {
+				since = envelope.Timestamp.Add(time.Nanosecond)
+			}
+			if !handle(envelope) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetProcessInstanceStates returns the state of each instance of the app's web process,
+// e.g. RUNNING, CRASHED, STARTING or DOWN
+func (c *Client) GetProcessInstanceStates(appGUID string) ([]string, error) {
+	stats, err := c.cf.Processes.GetStatsForApp(context.Background(), appGUID, "web")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get process stats: %w", err)
+	}
+
+	states := make([]string, 0, len(stats.Stats))
+	for _, stat := range stats.Stats {
+		states = append(states, stat.State)
+	}
+	return states, nil
+}
+
+func (c *Client) getJSON(url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,97 @@
+package cfclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// RunCredentialsTag marks the per-run user-provided service instances that hand secrets to the prompter
+const RunCredentialsTag = "cf-prompt-run"
+
+// CreateRunCredentials creates a user-provided service instance holding the credentials
+// of a single prompter run and binds it to the prompter app, so the secrets never
+// become part of the app's environment variables
+func (c *Client) CreateRunCredentials(name, spaceGUID, appGUID string, credentials map[string]string) error {
+	content, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+
+	create := resource.NewServiceInstanceCreateUserProvided(name, spaceGUID).
+		WithTags([]string{RunCredentialsTag}).
+		WithCredentials(content)
+
+	instance, err := c.cf.ServiceInstances.CreateUserProvided(context.Background(), create)
+	if err != nil {
+		return fmt.Errorf("failed to create service instance: %w", err)
+	}
+
+	if _, _, err := c.cf.ServiceCredentialBindings.Create(context.Background(), resource.NewServiceCredentialBindingCreateApp(instance.GUID, appGUID)); err != nil {
+		return fmt.Errorf("failed to bind service instance: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRunCredentials unbinds and deletes the run credentials service instance with the
+// given name. It does nothing if the instance no longer exists.
+func (c *Client) DeleteRunCredentials(name, spaceGUID string) error {
+	opts := client.NewServiceInstanceListOptions()
+	opts.Names = client.Filter{Values: []string{name}}
+	opts.SpaceGUIDs = client.Filter{Values: []string{spaceGUID}}
+
+	instances, err := c.cf.ServiceInstances.ListAll(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to list service instances: %w", err)
+	}
+
+	for _, instance := range instances {
+		bindingOpts := client.NewServiceCredentialBindingListOptions()
+		bindingOpts.ServiceInstanceGUIDs = client.Filter{Values: []string{instance.GUID}}
+
+		bindings, err := c.cf.ServiceCredentialBindings.ListAll(context.Background(), bindingOpts)
+		if err != nil {
+			return fmt.Errorf("failed to list service bindings: %w", err)
+		}
+
+		for _, binding := range bindings {
+			if _, err := c.cf.ServiceCredentialBindings.Delete(context.Background(), binding.GUID); err != nil {
+				return fmt.Errorf("failed to delete service binding: %w", err)
+			}
+		}
+
+		if _, err := c.cf.ServiceInstances.Delete(context.Background(), instance.GUID); err != nil {
+			return fmt.Errorf("failed to delete service instance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetRunCredentials returns the credentials held by the run credentials service instance
+// with the given name
+func (c *Client) GetRunCredentials(name, spaceGUID string) (map[string]string, error) {
+	opts := client.NewServiceInstanceListOptions()
+	opts.Names = client.Filter{Values: []string{name}}
+	opts.SpaceGUIDs = client.Filter{Values: []string{spaceGUID}}
+
+	instance, err := c.cf.ServiceInstances.Single(context.Background(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find service instance %s: %w", name, err)
+	}
+
+	content, err := c.cf.ServiceInstances.GetUserProvidedCredentials(context.Background(), instance.GUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials of service instance %s: %w", name, err)
+	}
+
+	var credentials map[string]string
+	if err := json.Unmarshal(*content, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials of service instance %s: %w", name, err)
+	}
+	return credentials, nil
+}
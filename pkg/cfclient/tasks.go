@@ -0,0 +1,77 @@
+package cfclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// Task states reported by the CF API
+const (
+	TaskPending   = "PENDING"
+	TaskRunning   = "RUNNING"
+	TaskCanceling = "CANCELING"
+	TaskSucceeded = "SUCCEEDED"
+	TaskFailed    = "FAILED"
+)
+
+// TaskFinished returns whether a task in the given state has ended
+func TaskFinished(state string) bool {
+	return state == TaskSucceeded || state == TaskFailed
+}
+
+// CreateTask runs the command as a task on the app's current droplet. The memory and
+// disk limits are taken from the app's web process.
+func (c *Client) CreateTask(appGUID, name, command string) (*resource.Task, error) {
+	process, err := c.cf.Processes.FirstForApp(context.Background(), appGUID, &client.ProcessListOptions{
+		ListOptions: client.NewListOptions(),
+		Types:       client.Filter{Values: []string{"web"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get web process: %w", err)
+	}
+
+	create := resource.NewTaskCreateWithProcessTemplate(process.GUID)
+	create.Name = &name
+	create.Command = &command
+
+	task, err := c.cf.Tasks.Create(context.Background(), appGUID, create)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return task, nil
+}
+
+// GetTask returns the task with the GUID
+func (c *Client) GetTask(taskGUID string) (*resource.Task, error) {
+	task, err := c.cf.Tasks.Get(context.Background(), taskGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return task, nil
+}
+
+// FindTask returns the task of the app with the name, ok is false if there is none
+func (c *Client) FindTask(appGUID, name string) (task *resource.Task, ok bool, err error) {
+	opts := client.NewTaskListOptions()
+	opts.Names = client.Filter{Values: []string{name}}
+
+	tasks, err := c.cf.Tasks.ListForAppAll(context.Background(), appGUID, opts)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil, false, nil
+	}
+	return tasks[0], true, nil
+}
+
+// CancelTask asks the platform to stop a running task
+func (c *Client) CancelTask(taskGUID string) error {
+	if _, err := c.cf.Tasks.Cancel(context.Background(), taskGUID); err != nil {
+		return fmt.Errorf("failed to cancel task: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package events defines the status events the prompter writes to its log, so that
+// 'cf prompt' can follow a run and tell how it ended. It is shared by the plugin and
+// the prompter binary.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Marker prefixes every event line in the prompter's log
+const Marker = "::cf-prompt-event::"
+
+// Version is the version of the event protocol. It is increased on incompatible changes,
+// adding event types or fields is compatible.
+const Version = 1
+
+const (
+	TypePhaseStarted   = "phase_started"
+	TypeAgentFinished  = "agent_finished"
+	TypeValidation     = "validation"
+	TypePackageCreated = "package_created"
+	TypeError          = "error"
+	TypeCancelled      = "cancelled"
+	TypeCompleted      = "completed"
+	// TypeCheck reports the result of a check run by 'prompter doctor'
+	TypeCheck = "check"
+)
+
+// Phases of a prompter run
+const (
+	PhaseDownload = "download"
+	PhaseAgent    = "agent"
+	PhaseValidate = "validate"
+	PhaseUpload   = "upload"
+)
+
+// Checks reported by 'prompter doctor'
+const (
+	CheckCFAPI               = "cf-api"
+	CheckOpencodeDownload    = "opencode-download"
+	CheckProviderCredentials = "provider-credentials"
+)
+
+// Event is a status update of a prompter run
+type Event struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	// RunID identifies the run the event belongs to, so events of earlier runs still in the log are ignored
+	RunID string `json:"run_id,omitempty"`
+	Phase string `json:"phase,omitempty"`
+	// Agent is the name and version of the agent, set on agent_finished
+	Agent string `json:"agent,omitempty"`
+	// Passed and Attempt describe a validation run, attempt 0 is the validation of the agent's initial changes
+	Passed      bool   `json:"passed,omitempty"`
+	Attempt     int    `json:"attempt,omitempty"`
+	PackageGUID string `json:"package_guid,omitempty"`
+	Message     string `json:"message,omitempty"`
+	// Check is the name of the check, set on check
+	Check string `json:"check,omitempty"`
+}
+
+// Emitter writes the events of a single run
+type Emitter struct {
+	w        io.Writer
+	runID    string
+	phase    string
+	handlers []func(Event)
+}
+
+func NewEmitter(w io.Writer, runID string) *Emitter {
+	return &Emitter{w: w, runID: runID}
+}
+
+// OnEmit registers a handler that is called with every emitted event
+func (e *Emitter) OnEmit(handler func(Event)) {
+	e.handlers = append(e.handlers, handler)
+}
+
+// Emit writes the event as a single marker line. Version, time, run ID and, unless set,
+// the current phase are filled in.
+func (e *Emitter) Emit(event Event) error {
+	event.Version = Version
+	event.Time = time.Now().UTC()
+	event.RunID = e.runID
+	if event.Type == TypePhaseStarted {
+		e.phase = event.Phase
+	}
+	if event.Phase == "" {
+		event.Phase = e.phase
+	}
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(e.w, "%s%s\n", Marker, content)
+
+	for _, handler := range e.handlers {
+		handler(event)
+	}
+	return err
+}
+
+// StartPhase reports that the run entered a new phase
+func (e *Emitter) StartPhase(phase string) error {
+	return e.Emit(Event{Type: TypePhaseStarted, Phase: phase})
+}
+
+// Parse returns the event on a log line, ok is false for regular log lines
+func Parse(line string) (event Event, ok bool) {
+	index := strings.Index(line, Marker)
+	if index < 0 {
+		return Event{}, false
+	}
+
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line[index+len(Marker):])), &event); err != nil {
+		return Event{}, false
+	}
+
+	return event, event.Type != ""
+}
+
+// Summary accumulates the events of a run into its result
+type Summary struct {
+	Phase       string
+	Agent       string
+	PackageGUID string
+	// Validated is set if the changes were validated, ValidationAttempts counts the fix-up attempts that were needed
+	Validated          bool
+	ValidationPassed   bool
+	ValidationAttempts int
+	Completed          bool
+	Cancelled          bool
+	Error              string
+}
+
+// Apply records the event and returns whether the run has ended
+func (s *Summary) Apply(event Event) bool {
+	if event.Phase != "" {
+		s.Phase = event.Phase
+	}
+
+	switch event.Type {
+	case TypeAgentFinished:
+		s.Agent = event.Agent
+	case TypeValidation:
+		s.Validated = true
+		s.ValidationPassed = event.Passed
+		s.ValidationAttempts = event.Attempt
+	case TypePackageCreated:
+		s.PackageGUID = event.PackageGUID
+	case TypeError:
+		s.Error = event.Message
+		return true
+	case TypeCancelled:
+		s.Cancelled = true
+		s.Error = "the run was cancelled"
+		return true
+	case TypeCompleted:
+		s.Completed = true
+		return true
+	}
+
+	return false
+}
+
+// Succeeded returns whether the run completed without error
+func (s *Summary) Succeeded() bool {
+	return s.Completed && s.Error == ""
+}
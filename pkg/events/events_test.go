@@ -0,0 +1,131 @@
+package events
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEmitAndParse(t *testing.T) {
+	var log bytes.Buffer
+	emitter := NewEmitter(&log, "run1")
+
+	var handled []string
+	emitter.OnEmit(func(event Event) {
+		handled = append(handled, event.Type)
+	})
+
+	emitter.StartPhase(PhaseAgent)
+	emitter.Emit(Event{Type: TypeAgentFinished, Agent: "opencode 0.14.3"})
+
+	var parsed []Event
+	for _, line := range strings.Split(log.String(), "\n") {
+		if event, ok := Parse("   [APP/PROC/WEB/0] OUT " + line); ok {
+			parsed = append(parsed, event)
+		}
+	}
+
+	if len(parsed) != 2 || len(handled) != 2 {
+		t.Fatalf("Expected 2 parsed and handled events, got %d and %d", len(parsed), len(handled))
+	}
+	for _, event := range parsed {
+		if event.Version != Version || event.RunID != "run1" || event.Time.IsZero() {
+			t.Errorf("Unexpected event header: %+v", event)
+		}
+	}
+	if parsed[1].Phase != PhaseAgent || parsed[1].Agent != "opencode 0.14.3" {
+		t.Errorf("Expected the agent event in the agent phase, got %+v", parsed[1])
+	}
+}
+
+func TestParseRegularLines(t *testing.T) {
+	for _, line := range []string{
+		"just a log line",
+		Marker + "not json",
+		Marker + `{"version": 1}`,
+	} {
+		if event, ok := Parse(line); ok {
+			t.Errorf("Expected %q not to be parsed as an event, got %+v", line, event)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    []Event
+		done      bool
+		succeeded bool
+		expected  Summary
+	}{
+		{
+			name: "Completed run",
+			events: []Event{
+				{Type: TypePhaseStarted, Phase: PhaseAgent},
+				{Type: TypeAgentFinished, Agent: "opencode 0.14.3"},
+				{Type: TypePhaseStarted, Phase: PhaseValidate},
+				{Type: TypeValidation, Passed: false, Attempt: 0},
+				{Type: TypeValidation, Passed: true, Attempt: 1},
+				{Type: TypePhaseStarted, Phase: PhaseUpload},
+				{Type: TypePackageCreated, PackageGUID: "0b7a0d6e"},
+				{Type: TypeCompleted},
+			},
+			done:      true,
+			succeeded: true,
+			expected: Summary{
+				Phase:              PhaseUpload,
+				Agent:              "opencode 0.14.3",
+				PackageGUID:        "0b7a0d6e",
+				Validated:          true,
+				ValidationPassed:   true,
+				ValidationAttempts: 1,
+				Completed:          true,
+			},
+		},
+		{
+			name: "Failed run",
+			events: []Event{
+				{Type: TypePhaseStarted, Phase: PhaseValidate},
+				{Type: TypeError, Message: "validation failed"},
+			},
+			done:     true,
+			expected: Summary{Phase: PhaseValidate, Error: "validation failed"},
+		},
+		{
+			name: "Cancelled run",
+			events: []Event{
+				{Type: TypePhaseStarted, Phase: PhaseAgent},
+				{Type: TypeCancelled},
+			},
+			done:     true,
+			expected: Summary{Phase: PhaseAgent, Cancelled: true, Error: "the run was cancelled"},
+		},
+		{
+			name: "Run in progress",
+			events: []Event{
+				{Type: TypePhaseStarted, Phase: PhaseDownload},
+			},
+			expected: Summary{Phase: PhaseDownload},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := Summary{}
+			done := false
+			for _, event := range tt.events {
+				done = summary.Apply(event)
+			}
+
+			if done != tt.done {
+				t.Errorf("Expected done=%v, got %v", tt.done, done)
+			}
+			if summary.Succeeded() != tt.succeeded {
+				t.Errorf("Expected succeeded=%v, got %v", tt.succeeded, summary.Succeeded())
+			}
+			if summary != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, summary)
+			}
+		})
+	}
+}
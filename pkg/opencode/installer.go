@@ -6,8 +6,10 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const OpencodeVersion = "0.14.3"
@@ -46,7 +48,14 @@ func getInstallDir() string {
 	return filepath.Join(homeDir, ".opencode", "bin")
 }
 
-func downloadAndInstall(installDir string) error {
+// ReleaseURLEnv names the environment variable that replaces the GitHub release
+// download URL, e.g. with a mirror that has the same v<VERSION>/<FILE> layout
+const ReleaseURLEnv = "OPENCODE_RELEASE_URL"
+
+const defaultReleaseURL = "https://github.com/sst/opencode/releases/download"
+
+// DownloadURL returns the URL of the opencode release for the current platform
+func DownloadURL() (string, error) {
 	osName := runtime.GOOS
 	arch := runtime.GOARCH
 
@@ -55,11 +64,23 @@ func downloadAndInstall(installDir string) error {
 	}
 
 	if err := validatePlatform(osName, arch); err != nil {
-		return err
+		return "", err
+	}
+
+	releaseURL := strings.TrimSuffix(os.Getenv(ReleaseURLEnv), "/")
+	if releaseURL == "" {
+		releaseURL = defaultReleaseURL
 	}
 
-	filename := fmt.Sprintf("opencode-%s-%s.zip", osName, arch)
-	url := fmt.Sprintf("https://github.com/sst/opencode/releases/download/v%s/%s", OpencodeVersion, filename)
+	return fmt.Sprintf("%s/v%s/opencode-%s-%s.zip", releaseURL, OpencodeVersion, osName, arch), nil
+}
+
+func downloadAndInstall(installDir string) error {
+	url, err := DownloadURL()
+	if err != nil {
+		return err
+	}
+	filename := path.Base(url)
 
 	tempDir, err := os.MkdirTemp("", "opencode-install-*")
 	if err != nil {
@@ -1,6 +1,7 @@
 package opencode
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -9,11 +10,20 @@ import (
 	"runtime"
 )
 
-func Run(workDir, prompt string, stdout io.Writer) error {
+// Run executes 'opencode run' with the prompt in workDir and the environment env. model
+// is in provider/model notation, opencode's configured default model is used when it is empty.
+func Run(ctx context.Context, workDir, prompt, model string, env []string, stdout io.Writer) error {
 	binaryPath := getOpencodeBinaryPath()
-	
-	cmd := exec.Command(binaryPath, "run", prompt)
+
+	args := []string{"run"}
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	args = append(args, prompt)
+
+	cmd := exec.CommandContext(ctx, binaryPath, args...)
 	cmd.Dir = workDir
+	cmd.Env = env
 	cmd.Stdout = stdout
 	cmd.Stderr = os.Stderr
 
@@ -3,22 +3,145 @@ package prompter
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"code.cloudfoundry.org/cli/plugin"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
 )
 
+// SharedPrompterName is the name of the prompter app that serves every app of a space
+// without a prompter of its own
+const SharedPrompterName = "shared-prompter"
+
+// PrompterName returns the name of the prompter app dedicated to an app
+func PrompterName(appName string) string {
+	return fmt.Sprintf("%s-prompter", appName)
+}
+
+// BasePackageCurrent selects the package behind the app's current droplet as the base package
+const BasePackageCurrent = "current"
+
+// RunOptions holds the per-run settings passed to the prompter app
+type RunOptions struct {
+	// BasePackage is a package short hash or BasePackageCurrent. When empty the
+	// prompter starts from the latest package.
+	BasePackage string
+	// Continue makes the prompter treat the prompt as a follow-up to the prompts
+	// that produced the base package
+	Continue bool
+	// ValidateCommand overrides the validation command from the app's .cf-prompt.yml
+	ValidateCommand string
+	// ValidateAttempts overrides the number of fix-up attempts after a failed validation
+	ValidateAttempts int
+	// Agent selects the coding agent backend, the prompter defaults to opencode when empty
+	Agent string
+	// AgentCommand is the shell command run by the "command" agent
+	AgentCommand string
+	// Model and Provider select the language model used by the agent
+	Model    string
+	Provider string
+	// VariantGroup and VariantIndex identify the run as one of several alternatives for the same prompt
+	VariantGroup string
+	VariantIndex int
+	// Template and TemplateVars record the template the prompt was rendered from
+	Template     string
+	TemplateVars map[string]string
+
+	// Owner is recorded in the lock on the prompter app, Force breaks a lock held by
+	// another run. Both are not passed to the prompter.
+	Owner string
+	Force bool
+	// Task runs the prompt as a CF task on the prompter's droplet instead of starting
+	// the prompter app. Task runs do not lock the prompter and may run in parallel.
+	Task bool
+}
+
+// LockedError is returned by StartPrompter when another run holds the lock on the prompter app
+type LockedError struct {
+	Lock runstate.Lock
+	// AppGUID is the app the locking run works on, if its state is recorded
+	AppGUID string
+}
+
+// lockedError returns the LockedError for the lock recorded in the annotations of the prompter
+func lockedError(annotations map[string]string, lock runstate.Lock) *LockedError {
+	locked := &LockedError{Lock: lock}
+	if state, ok := runstate.FromAnnotations(annotations); ok && state.RunID == lock.RunID {
+		locked.AppGUID = state.AppGUID
+	}
+	return locked
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("the prompter is in use by %s (run %s) until %s",
+		e.Lock.Owner, e.Lock.RunID, e.Lock.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+func (o RunOptions) env() map[string]string {
+	env := map[string]string{
+		"BASE_PACKAGE":          o.BasePackage,
+		"CONTINUE_CONVERSATION": "",
+		"VALIDATE_COMMAND":      o.ValidateCommand,
+		"VALIDATE_ATTEMPTS":     "",
+		"AGENT":                 o.Agent,
+		"AGENT_COMMAND":         o.AgentCommand,
+		"MODEL":                 o.Model,
+		"PROVIDER":              o.Provider,
+		"VARIANT_GROUP":         o.VariantGroup,
+		"VARIANT_INDEX":         "",
+		"TEMPLATE":              o.Template,
+		"TEMPLATE_VARS":         "",
+	}
+	if len(o.TemplateVars) > 0 {
+		// Keys are sorted by the encoder, so the annotation is stable
+		vars, _ := json.Marshal(o.TemplateVars)
+		env["TEMPLATE_VARS"] = string(vars)
+	}
+	if o.VariantIndex > 0 {
+		env["VARIANT_INDEX"] = strconv.Itoa(o.VariantIndex)
+	}
+	if o.Continue {
+		env["CONTINUE_CONVERSATION"] = "true"
+	}
+	if o.ValidateAttempts > 0 {
+		env["VALIDATE_ATTEMPTS"] = strconv.Itoa(o.ValidateAttempts)
+	}
+	return env
+}
+
 type AppDeployer struct {
 	cliConnection plugin.CliConnection
 	appName       string
 	cfClient      *cfclient.Client
+	spaceID       string
+	prompterGUID  string
+	runID         string
+	startedAt     time.Time
+	// taskGUID is set when the run is a CF task
+	taskGUID string
+}
+
+// credentialsName returns the name of the service instance holding the credentials of the current run
+func (d *AppDeployer) credentialsName() string {
+	return RunCredentialsName(d.appName, d.runID)
+}
+
+// RunCredentialsName returns the name of the service instance holding the credentials
+// of a run of the prompter app, or of a prompt queued on it
+func RunCredentialsName(prompterName, runID string) string {
+	return fmt.Sprintf("%s-run-%s", prompterName, runID)
 }
 
 func NewAppDeployer(cliConnection plugin.CliConnection, appName string) *AppDeployer {
@@ -28,7 +151,45 @@ func NewAppDeployer(cliConnection plugin.CliConnection, appName string) *AppDepl
 	}
 }
 
-func (d *AppDeployer) StartPrompter(apiEndpoint, token, appID, spaceID, orgID, registryUsername, registryPassword, prompt string) error {
+// RunID returns the ID of the run started or attached to
+func (d *AppDeployer) RunID() string {
+	return d.runID
+}
+
+// IsTask returns whether the run is a CF task rather than a run of the prompter app
+func (d *AppDeployer) IsTask() bool {
+	return d.taskGUID != ""
+}
+
+// TaskName returns the name of the CF task of a run
+func TaskName(runID string) string {
+	return "prompt-" + runID
+}
+
+// Attach prepares the deployer to monitor a run that was started earlier, for example with 'cf prompt --no-wait'
+func (d *AppDeployer) Attach(apiEndpoint, token, spaceID string, state runstate.State) error {
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		return fmt.Errorf("failed to create CF client: %w", err)
+	}
+	d.cfClient = client
+	d.spaceID = spaceID
+	d.runID = state.RunID
+
+	prompterGUID, err := client.GetAppGUID(d.appName, spaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get prompter app GUID: %w", err)
+	}
+	d.prompterGUID = prompterGUID
+	d.startedAt = logsSince(state.StartedAt)
+
+	if task, ok, err := client.FindTask(prompterGUID, TaskName(state.RunID)); err == nil && ok {
+		d.taskGUID = task.GUID
+	}
+	return nil
+}
+
+func (d *AppDeployer) StartPrompter(apiEndpoint, token, appID, spaceID, orgID, registryUsername, registryPassword, prompt string, opts RunOptions) error {
 	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
 		token = token[7:]
 	}
@@ -38,38 +199,94 @@ func (d *AppDeployer) StartPrompter(apiEndpoint, token, appID, spaceID, orgID, r
 		return fmt.Errorf("failed to create CF client: %w", err)
 	}
 	d.cfClient = client
+	d.spaceID = spaceID
 
-	promptBase64 := base64.StdEncoding.EncodeToString([]byte(prompt))
+	prompterGUID, err := client.GetAppGUID(d.appName, spaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get prompter app GUID: %w", err)
+	}
 
-	prompterApiEndpoint := apiEndpoint
-	if strings.Contains(apiEndpoint, "localhost") {
-		prompterApiEndpoint = "https://korifi-api-svc.korifi.svc.cluster.local"
+	runID, err := runstate.NewRunID()
+	if err != nil {
+		return err
 	}
+	d.runID = runID
 
-	fmt.Printf("Setting environment variables for prompter app '%s'...\n", d.appName)
+	if opts.Task {
+		if err := d.checkLock(prompterGUID); err != nil {
+			return err
+		}
+		d.prompterGUID = prompterGUID
+	} else if err := d.acquireLock(prompterGUID, opts.Owner, opts.Force); err != nil {
+		return err
+	}
 
-	envVars := map[string]string{
+	// A run that fails to start leaves neither its credentials nor the lock behind
+	started := false
+	defer func() {
+		if !started {
+			d.RemoveRunCredentials()
+			d.ReleaseLock()
+		}
+	}()
+
+	promptBase64 := base64.StdEncoding.EncodeToString([]byte(prompt))
+
+	prompterApiEndpoint := PrompterAPIEndpoint(apiEndpoint)
+
+	// Secrets are handed over in a service instance that only lives for this run,
+	// instead of environment variables that stay readable on the app
+	fmt.Printf("Creating credentials for run %s...\n", runID)
+	if err := client.CreateRunCredentials(d.credentialsName(), spaceID, prompterGUID, map[string]string{
+		"run_id":            runID,
 		"CF_ACCESS_TOKEN":   token,
-		"CF_API":            prompterApiEndpoint,
-		"APP_ID":            appID,
-		"SPACE_ID":          spaceID,
-		"ORG_ID":            orgID,
 		"REGISTRY_USERNAME": registryUsername,
 		"REGISTRY_PASSWORD": registryPassword,
 		"PROMPT_BASE64":     promptBase64,
+	}); err != nil {
+		return fmt.Errorf("failed to create run credentials: %w", err)
+	}
+
+	envVars := map[string]string{
+		"CF_API":   prompterApiEndpoint,
+		"APP_ID":   appID,
+		"SPACE_ID": spaceID,
+		"ORG_ID":   orgID,
+		"RUN_ID":   runID,
+	}
+	for key, value := range opts.env() {
+		envVars[key] = value
+	}
+
+	if opts.Task {
+		if err := d.startTask(prompt, envVars); err != nil {
+			return err
+		}
+		started = true
+		return nil
+	}
+
+	fmt.Printf("Setting environment variables for prompter app '%s'...\n", d.appName)
+
+	// Unset secrets left behind by earlier versions of the plugin
+	for _, key := range []string{"CF_ACCESS_TOKEN", "REGISTRY_USERNAME", "REGISTRY_PASSWORD", "PROMPT_BASE64"} {
+		envVars[key] = ""
 	}
 
 	for key, value := range envVars {
-		if _, err := d.cliConnection.CliCommand("set-env", d.appName, key, value); err != nil {
-			// Try manual set-env as fallback
-			cmd := exec.Command("cf", "set-env", d.appName, key, value)
-			if output, setEnvErr := cmd.CombinedOutput(); setEnvErr != nil {
-				return fmt.Errorf("failed to set environment variable %s: %v\nOutput: %s", key, setEnvErr, string(output))
-			}
+		if err := d.setEnv(key, value); err != nil {
+			return err
 		}
 	}
 
+	state := runstate.New(runID, prompt)
+	state.AppGUID = appID
+	if err := client.UpdateAppAnnotations(prompterGUID, state.Annotations()); err != nil {
+		return fmt.Errorf("failed to record run state: %w", err)
+	}
+
 	fmt.Printf("Starting prompter app '%s'...\n", d.appName)
+	d.startedAt = logsSince(state.StartedAt)
 	if _, err := d.cliConnection.CliCommand("start", d.appName); err != nil {
 		// Try manual start as fallback
 		cmd := exec.Command("cf", "start", d.appName)
@@ -78,81 +295,287 @@ func (d *AppDeployer) StartPrompter(apiEndpoint, token, appID, spaceID, orgID, r
 		}
 	}
 
+	started = true
 	fmt.Println("Prompter app started successfully")
 	return nil
 }
 
-func (d *AppDeployer) MonitorLogs(stdout io.Writer) error {
+// startTask launches the run as a CF task on the prompter's droplet. Tasks cannot be
+// given environment variables of their own, so the settings of the run are passed on
+// the task's command line.
+func (d *AppDeployer) startTask(prompt string, envVars map[string]string) error {
+	envVars["PROMPTER_MODE"] = "task"
+
+	// Tasks run on the droplet staged by 'cf prompt-init'
+	dropletGUID, err := d.cfClient.GetCurrentDropletGUID(d.prompterGUID)
+	if err != nil {
+		return err
+	}
+	if dropletGUID == "" {
+		return fmt.Errorf("prompter app '%s' has no staged droplet, run 'cf prompt-init' again", d.appName)
+	}
+
+	state := runstate.New(d.runID, prompt)
+	state.AppGUID = envVars["APP_ID"]
+	if err := d.cfClient.UpdateAppAnnotations(d.prompterGUID, state.Annotations()); err != nil {
+		return fmt.Errorf("failed to record run state: %w", err)
+	}
+
+	fmt.Printf("Starting task %s on prompter app '%s'...\n", TaskName(d.runID), d.appName)
+	d.startedAt = logsSince(state.StartedAt)
+	task, err := d.cfClient.CreateTask(d.prompterGUID, TaskName(d.runID), taskCommand(envVars))
+	if err != nil {
+		return err
+	}
+	d.taskGUID = task.GUID
+
+	fmt.Println("Prompter task started successfully")
+	return nil
+}
+
+// logsSince returns the time from which the logs of a run started at startedAt are read,
+// allowing for clock skew between the machine that started the run and Log Cache
+func logsSince(startedAt time.Time) time.Time {
+	return startedAt.Add(-time.Minute)
+}
+
+// taskCommand returns the shell command that runs the prompter with the environment
+// variables. Empty values are passed as well, so they override settings left on the
+// app by runs of the prompter app.
+func taskCommand(envVars map[string]string) string {
+	keys := make([]string, 0, len(envVars))
+	for key := range envVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{"env"}
+	for _, key := range keys {
+		parts = append(parts, key+"="+shellQuote(envVars[key]))
+	}
+	parts = append(parts, "./prompter")
+	return strings.Join(parts, " ")
+}
+
+// DoctorTaskCommand returns the command of the task running 'prompter doctor', which
+// checks the CF API at the endpoint a run of the prompter would use
+func DoctorTaskCommand(apiEndpoint string) string {
+	return taskCommand(map[string]string{"CF_API": PrompterAPIEndpoint(apiEndpoint)}) + " doctor"
+}
+
+// PrompterAPIEndpoint returns the CF API endpoint as seen from inside the prompter. A
+// local Korifi is reached through its API service in the cluster.
+func PrompterAPIEndpoint(apiEndpoint string) string {
+	if strings.Contains(apiEndpoint, "localhost") {
+		return "https://korifi-api-svc.korifi.svc.cluster.local"
+	}
+	return apiEndpoint
+}
+
+// shellQuote quotes a value for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// setEnv sets an environment variable on the prompter app. Empty values are unset
+// so that options from a previous run do not leak into the next one.
+func (d *AppDeployer) setEnv(key, value string) error {
+	args := []string{"set-env", d.appName, key, value}
+	if value == "" {
+		args = []string{"unset-env", d.appName, key}
+	}
+
+	if _, err := d.cliConnection.CliCommand(args...); err != nil {
+		// Try manual command as fallback
+		cmd := exec.Command("cf", args...)
+		if output, cmdErr := cmd.CombinedOutput(); cmdErr != nil {
+			return fmt.Errorf("failed to %s %s: %v\nOutput: %s", args[0], key, cmdErr, string(output))
+		}
+	}
+	return nil
+}
+
+// MonitorLogs streams the prompter's logs from Log Cache until the prompter reports
+// the end of the run, and returns the run's summary. It returns an error if the run
+// failed, the prompter crashed or stopped without reporting a result, or the run timed
+// out. The summary is nil if no result was reported.
+func (d *AppDeployer) MonitorLogs(stdout io.Writer) (*events.Summary, error) {
 	fmt.Println("Monitoring logs for completion...")
 
 	if d.cfClient == nil {
-		return fmt.Errorf("CF client not initialized - Deploy must be called first")
+		return nil, fmt.Errorf("CF client not initialized - Deploy must be called first")
 	}
 
 	currentSpace, err := d.cliConnection.GetCurrentSpace()
 	if err != nil {
-		return fmt.Errorf("failed to get current space: %w", err)
+		return nil, fmt.Errorf("failed to get current space: %w", err)
 	}
 
 	appGUID, err := d.cfClient.GetAppGUID(d.appName, currentSpace.Guid)
 	if err != nil {
-		return fmt.Errorf("failed to get prompter app GUID: %w", err)
+		return nil, fmt.Errorf("failed to get prompter app GUID: %w", err)
 	}
 
-	cmd := exec.Command("cf", "logs", d.appName)
-	cmd.Stdout = stdout
-	cmd.Stderr = stdout
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start log streaming: %w", err)
-	}
+	results := make(chan *events.Summary, 1)
+	logsFailed := make(chan error, 1)
 
-	done := make(chan error, 1)
 	go func() {
-		done <- cmd.Wait()
-	}()
+		summary := &events.Summary{}
+		warnedVersion := false
 
-	appStateChan := make(chan string, 1)
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
+		err := d.cfClient.TailLogs(ctx, appGUID, d.startedAt, 2*time.Second, func(envelope cfclient.LogEnvelope) bool {
+			// Other task runs may log to the prompter app at the same time
+			if d.IsTask() && envelope.SourceType != "APP/TASK/"+TaskName(d.runID) {
+				return true
+			}
 
-		for {
-			select {
-			case <-ticker.C:
-				app, err := d.cfClient.GetApp(appGUID)
+			event, ok := events.Parse(envelope.Message)
+			if !ok {
+				fmt.Fprintln(stdout, envelope.Message)
+				return true
+			}
+			if event.RunID != "" && event.RunID != d.runID {
+				return true
+			}
+
+			if event.Version > events.Version && !warnedVersion {
+				fmt.Fprintf(stdout, "Warning: the prompter uses a newer event protocol (version %d), run 'cf prompt-init' with an updated plugin\n", event.Version)
+				warnedVersion = true
+			}
+
+			if event.Type == events.TypePhaseStarted {
+				fmt.Fprintf(stdout, "--- %s ---\n", event.Phase)
+			}
+
+			if summary.Apply(event) {
+				results <- summary
+				return false
+			}
+			return true
+		})
+		if err != nil && ctx.Err() == nil {
+			logsFailed <- err
+		}
+	}()
+
+	// The app or task state is watched as well, to notice crashes and prompters that
+	// exit without reporting a result. Log Cache lags behind, so a result reported just
+	// before the prompter exited is waited for briefly.
+	logsAvailable := true
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	leaseTicker := time.NewTicker(leaseCheckInterval)
+	defer leaseTicker.Stop()
+
+	for {
+		select {
+		case summary := <-results:
+			return summary, summaryError(summary)
+		case err := <-logsFailed:
+			fmt.Fprintf(stdout, "Warning: failed to read prompter logs, only watching the app state: %v\n", err)
+			logsAvailable = false
+		case <-leaseTicker.C:
+			if d.IsTask() {
+				continue
+			}
+			annotations, err := d.cfClient.GetAppAnnotations(appGUID)
+			if err != nil {
+				continue
+			}
+			// The prompter renews the lease while it runs, an expired one means it hangs
+			if lock, ok := runstate.LockFromAnnotations(annotations); ok && lock.RunID == d.runID && !lock.Active(time.Now()) {
+				return nil, fmt.Errorf("timeout waiting for prompter to complete, it stopped renewing the lease of run %s", d.runID)
+			}
+		case <-ticker.C:
+			if d.IsTask() {
+				task, err := d.cfClient.GetTask(d.taskGUID)
 				if err != nil {
-					fmt.Fprintf(stdout, "Warning: failed to get app state: %v\n", err)
+					fmt.Fprintf(stdout, "Warning: failed to get task state: %v\n", err)
 					continue
 				}
+				if !cfclient.TaskFinished(task.State) {
+					continue
+				}
+				return d.taskResult(stdout, task, results, logsAvailable)
+			}
+
+			app, err := d.cfClient.GetApp(appGUID)
+			if err != nil {
+				fmt.Fprintf(stdout, "Warning: failed to get app state: %v\n", err)
+				continue
+			}
 
-				if app.State == "STOPPED" {
-					fmt.Fprintf(stdout, "\nPrompter app detected as STOPPED - task completed successfully\n")
-					appStateChan <- "STOPPED"
-					return
+			crashed := false
+			if states, err := d.cfClient.GetProcessInstanceStates(appGUID); err == nil {
+				for _, state := range states {
+					crashed = crashed || state == "CRASHED"
 				}
 			}
-		}
-	}()
 
-	timeout := time.After(30 * time.Minute)
+			if app.State != "STOPPED" && !crashed {
+				continue
+			}
 
-	select {
-	case <-timeout:
-		cmd.Process.Kill()
-		return fmt.Errorf("timeout waiting for prompter to complete")
-	case state := <-appStateChan:
-		cmd.Process.Kill()
-		if state == "STOPPED" {
-			return nil
+			if !logsAvailable {
+				if crashed {
+					return nil, fmt.Errorf("prompter app crashed")
+				}
+				fmt.Fprintf(stdout, "\nPrompter app detected as STOPPED - task completed\n")
+				return nil, nil
+			}
+
+			select {
+			case summary := <-results:
+				return summary, summaryError(summary)
+			case <-time.After(15 * time.Second):
+				if crashed {
+					return nil, fmt.Errorf("prompter app crashed without reporting a result")
+				}
+				return nil, fmt.Errorf("prompter app stopped without reporting a result")
+			}
 		}
-		return fmt.Errorf("unexpected app state: %s", state)
-	case err := <-done:
-		if err != nil {
-			return fmt.Errorf("log streaming failed: %w", err)
+	}
+}
+
+// taskResult returns the result of a task run that has ended. The exit status of the
+// task decides when the prompter did not report a result.
+func (d *AppDeployer) taskResult(stdout io.Writer, task *resource.Task, results <-chan *events.Summary, logsAvailable bool) (*events.Summary, error) {
+	if logsAvailable {
+		select {
+		case summary := <-results:
+			return summary, summaryError(summary)
+		case <-time.After(15 * time.Second):
+		}
+	}
+
+	if task.State == cfclient.TaskFailed {
+		reason := "unknown reason"
+		if task.Result.FailureReason != nil {
+			reason = *task.Result.FailureReason
 		}
+		return nil, fmt.Errorf("prompter task %s failed without reporting a result: %s", task.Name, reason)
+	}
+	if logsAvailable {
+		return nil, fmt.Errorf("prompter task %s succeeded without reporting a result", task.Name)
+	}
+	fmt.Fprintf(stdout, "\nPrompter task %s succeeded\n", task.Name)
+	return nil, nil
+}
+
+func summaryError(summary *events.Summary) error {
+	if summary.Succeeded() {
 		return nil
 	}
+	if summary.Cancelled {
+		return fmt.Errorf("prompt run was cancelled")
+	}
+	if summary.Phase != "" {
+		return fmt.Errorf("prompter failed during %s: %s", summary.Phase, summary.Error)
+	}
+	return fmt.Errorf("prompter failed: %s", summary.Error)
 }
 
 func (d *AppDeployer) Cleanup() error {
@@ -165,6 +588,144 @@ func (d *AppDeployer) Cleanup() error {
 	return nil
 }
 
+// stopTimeout is how long a prompter that runs a locked run may take to stop when the
+// lock is broken
+const stopTimeout = time.Minute
+
+// leaseCheckInterval is how often MonitorLogs makes sure the prompter still renews the
+// lease of the run, a fraction of runstate.LeaseDuration
+const leaseCheckInterval = runstate.LeaseDuration / 12
+
+// acquireLock takes the lease on the prompter app for the current run. Annotations
+// cannot be updated conditionally, so the lock is read back after a moment to detect
+// a concurrent run that took it at the same time.
+func (d *AppDeployer) acquireLock(prompterGUID, owner string, force bool) error {
+	annotations, err := d.cfClient.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return fmt.Errorf("failed to read prompter lock: %w", err)
+	}
+
+	if lock, blocking := runstate.Blocking(annotations, time.Now()); blocking {
+		if !force {
+			return lockedError(annotations, lock)
+		}
+		fmt.Printf("Breaking lock held by %s (run %s)...\n", lock.Owner, lock.RunID)
+		// The prompter of the locked run would go on and overwrite the state of this
+		// run, and starting an app that runs does nothing, so it is stopped first
+		if err := d.stopPrompterApp(prompterGUID); err != nil {
+			return err
+		}
+	}
+
+	if err := d.cfClient.UpdateAppAnnotations(prompterGUID, runstate.NewLock(owner, d.runID).Annotations()); err != nil {
+		return fmt.Errorf("failed to lock prompter: %w", err)
+	}
+	d.prompterGUID = prompterGUID
+
+	time.Sleep(2 * time.Second)
+
+	annotations, err = d.cfClient.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return fmt.Errorf("failed to read prompter lock: %w", err)
+	}
+	if lock, ok := runstate.LockFromAnnotations(annotations); !ok || lock.RunID != d.runID {
+		return lockedError(annotations, lock)
+	}
+
+	return nil
+}
+
+// checkLock returns a LockedError while a run of the prompter app holds its lock. Task
+// runs do not take the lock, but they record their state in the same annotations as
+// the run holding it, so they are not started until it ends.
+func (d *AppDeployer) checkLock(prompterGUID string) error {
+	annotations, err := d.cfClient.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return fmt.Errorf("failed to read prompter lock: %w", err)
+	}
+	if lock, blocking := runstate.Blocking(annotations, time.Now()); blocking {
+		return lockedError(annotations, lock)
+	}
+	return nil
+}
+
+// stopPrompterApp stops the prompter app and waits until none of its instances runs
+func (d *AppDeployer) stopPrompterApp(prompterGUID string) error {
+	fmt.Printf("Stopping prompter app '%s'...\n", d.appName)
+	if err := d.cfClient.StopApp(prompterGUID); err != nil {
+		return fmt.Errorf("failed to stop prompter app: %w", err)
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for time.Now().Before(deadline) {
+		states, err := d.cfClient.GetProcessInstanceStates(prompterGUID)
+		if err == nil && !instancesRunning(states) {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("prompter app '%s' did not stop within %s", d.appName, stopTimeout)
+}
+
+// instancesRunning returns whether any instance in the given states has not stopped yet
+func instancesRunning(states []string) bool {
+	for _, state := range states {
+		if state != "DOWN" && state != "CRASHED" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseLock releases the lock on the prompter app if it is still held by the current
+// run. The prompter releases it itself when the run ends, this covers runs that failed
+// early or were cancelled.
+func (d *AppDeployer) ReleaseLock() error {
+	if d.cfClient == nil || d.prompterGUID == "" {
+		return nil
+	}
+
+	annotations, err := d.cfClient.GetAppAnnotations(d.prompterGUID)
+	if err != nil {
+		return err
+	}
+	if lock, ok := runstate.LockFromAnnotations(annotations); !ok || lock.RunID != d.runID {
+		return nil
+	}
+
+	return d.cfClient.UpdateAppAnnotations(d.prompterGUID, runstate.ReleaseAnnotations())
+}
+
+// RemoveRunCredentials unbinds and deletes the credentials of the current run. The
+// prompter removes them itself when it finishes, this covers runs that failed early.
+func (d *AppDeployer) RemoveRunCredentials() error {
+	if d.cfClient == nil || d.runID == "" {
+		return nil
+	}
+	return d.cfClient.DeleteRunCredentials(d.credentialsName(), d.spaceID)
+}
+
+// ProcessingQueue returns whether the prompter went on with a queued prompt after the
+// current run, in which case it must be left running
+func (d *AppDeployer) ProcessingQueue() bool {
+	if d.cfClient == nil || d.prompterGUID == "" || d.IsTask() {
+		return false
+	}
+
+	annotations, err := d.cfClient.GetAppAnnotations(d.prompterGUID)
+	if err != nil {
+		return false
+	}
+	state, ok := runstate.FromAnnotations(annotations)
+	if !ok {
+		return false
+	}
+	if state.RunID != d.runID {
+		return !state.Finished()
+	}
+	return state.Status == runstate.StatusSucceeded && len(runstate.Queue(annotations)) > 0
+}
+
 func (d *AppDeployer) StopPrompter() error {
 	if _, err := d.cliConnection.CliCommand("stop", d.appName); err != nil {
 		// Try manual stop as fallback
@@ -187,11 +748,30 @@ func NewPrompterInitDeployer(cliConnection plugin.CliConnection, appName string)
 	return &PrompterInitDeployer{
 		cliConnection: cliConnection,
 		appName:       appName,
-		prompterName:  fmt.Sprintf("%s-prompter", appName),
+		prompterName:  PrompterName(appName),
 	}
 }
 
-func (d *PrompterInitDeployer) DeployPrompterApp(apiEndpoint, token string) error {
+// NewSharedPrompterInitDeployer returns a deployer for the shared prompter of the space,
+// which takes the app to work on from each run
+func NewSharedPrompterInitDeployer(cliConnection plugin.CliConnection) *PrompterInitDeployer {
+	return &PrompterInitDeployer{
+		cliConnection: cliConnection,
+		prompterName:  SharedPrompterName,
+	}
+}
+
+// PrompterName returns the name of the prompter app the deployer pushes
+func (d *PrompterInitDeployer) PrompterName() string {
+	return d.prompterName
+}
+
+// DeployPrompterApp pushes the prompter app configured by opts, replacing an existing one
+func (d *PrompterInitDeployer) DeployPrompterApp(apiEndpoint, token string, opts InitOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
 		token = token[7:]
 	}
@@ -222,35 +802,24 @@ func (d *PrompterInitDeployer) DeployPrompterApp(apiEndpoint, token string) erro
 	}
 	defer os.RemoveAll(tempDir)
 
-	prompterBinaryPath := filepath.Join(tempDir, "prompter")
-
-	if len(PrompterBinary) == 0 {
-		return fmt.Errorf("prompter binary not embedded - ensure 'make build-prompter' was run before building the plugin")
-	}
+	pushArgs := []string{"push", d.prompterName}
 
-	if err := os.WriteFile(prompterBinaryPath, PrompterBinary, 0755); err != nil {
-		return fmt.Errorf("failed to write prompter binary: %w", err)
+	// A docker image brings its own prompter, otherwise the embedded binary is staged
+	if opts.DockerImage == "" {
+		appDir := filepath.Join(tempDir, "app")
+		if err := writePrompterSource(appDir); err != nil {
+			return err
+		}
+		pushArgs = append(pushArgs, "-p", appDir)
 	}
 
-	procfilePath := filepath.Join(tempDir, "Procfile")
-	procfile := "web: ./prompter\n"
-	if err := os.WriteFile(procfilePath, []byte(procfile), 0644); err != nil {
-		return fmt.Errorf("failed to write Procfile: %w", err)
+	manifestPath := filepath.Join(tempDir, "manifest.yml")
+	manifest, err := opts.Manifest(d.prompterName)
+	if err != nil {
+		return err
 	}
 
-	manifestPath := filepath.Join(tempDir, "manifest.yml")
-	manifest := fmt.Sprintf(`---
-applications:
-- name: %s
-  memory: 1G
-  disk_quota: 2G
-  instances: 1
-  no-route: true
-  health-check-type: process
-  buildpack: paketo-buildpacks/procfile
-`, d.prompterName)
-
-	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+	if err := os.WriteFile(manifestPath, manifest, 0644); err != nil {
 		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 
@@ -274,7 +843,8 @@ applications:
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "cf", "push", d.prompterName, "-p", tempDir, "-f", manifestPath, "--no-wait")
+	pushArgs = append(pushArgs, "-f", manifestPath, "--no-wait")
+	cmd := exec.CommandContext(ctx, "cf", pushArgs...)
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	err = cmd.Run()
@@ -314,5 +884,12 @@ applications:
 		}
 	}
 
+	// The version lets 'cf prompt-init --upgrade' tell whether the prompter is outdated
+	if prompterGUID, err := d.cfClient.GetAppGUID(d.prompterName, currentSpace.Guid); err != nil {
+		fmt.Printf("Warning: failed to record prompter version: %v\n", err)
+	} else if err := d.cfClient.UpdateAppLabels(prompterGUID, map[string]string{cfclient.LabelPrompterVersion: opts.Version()}); err != nil {
+		fmt.Printf("Warning: failed to record prompter version: %v\n", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,138 @@
+package prompter
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the prompter app pushed by 'cf prompt-init'
+const (
+	DefaultMemory    = "1G"
+	DefaultDisk      = "2G"
+	DefaultBuildpack = "paketo-buildpacks/procfile"
+)
+
+var sizePattern = regexp.MustCompile(`^(?i)[1-9][0-9]*(M|MB|G|GB)$`)
+
+// InitOptions configures the prompter app pushed by 'cf prompt-init'. Empty fields
+// use the defaults.
+type InitOptions struct {
+	Memory    string `yaml:"memory"`
+	Disk      string `yaml:"disk"`
+	Buildpack string `yaml:"buildpack"`
+	Stack     string `yaml:"stack"`
+	// Env holds extra environment variables, e.g. a proxy or provider settings
+	Env map[string]string `yaml:"env"`
+	// Services are service instances bound to the prompter
+	Services []string `yaml:"services"`
+	// DockerImage runs the prompter from an image instead of staging the embedded binary.
+	// The image must start the prompter as './prompter' from its working directory.
+	DockerImage    string `yaml:"docker_image"`
+	DockerUsername string `yaml:"docker_username"`
+}
+
+// Validate checks the options for values CF would reject
+func (o InitOptions) Validate() error {
+	if o.Memory != "" && !sizePattern.MatchString(o.Memory) {
+		return fmt.Errorf("invalid memory '%s', use a size like 1G or 512M", o.Memory)
+	}
+	if o.Disk != "" && !sizePattern.MatchString(o.Disk) {
+		return fmt.Errorf("invalid disk '%s', use a size like 4G or 2048M", o.Disk)
+	}
+	if o.DockerImage != "" && (o.Buildpack != "" || o.Stack != "") {
+		return fmt.Errorf("a docker image cannot be combined with a buildpack or stack")
+	}
+	if o.DockerUsername != "" && o.DockerImage == "" {
+		return fmt.Errorf("a docker username requires a docker image")
+	}
+	return nil
+}
+
+// Merge returns the options with the fields set in override replacing those of o. Env
+// variables are merged, services are added.
+func (o InitOptions) Merge(override InitOptions) InitOptions {
+	merged := o
+	for _, field := range []struct {
+		target *string
+		value  string
+	}{
+		{&merged.Memory, override.Memory},
+		{&merged.Disk, override.Disk},
+		{&merged.Buildpack, override.Buildpack},
+		{&merged.Stack, override.Stack},
+		{&merged.DockerImage, override.DockerImage},
+		{&merged.DockerUsername, override.DockerUsername},
+	} {
+		if field.value != "" {
+			*field.target = field.value
+		}
+	}
+
+	if len(override.Env) > 0 {
+		merged.Env = make(map[string]string, len(o.Env)+len(override.Env))
+		for key, value := range o.Env {
+			merged.Env[key] = value
+		}
+		for key, value := range override.Env {
+			merged.Env[key] = value
+		}
+	}
+
+	if len(override.Services) > 0 {
+		merged.Services = append(append([]string{}, o.Services...), override.Services...)
+	}
+	return merged
+}
+
+// Manifest returns the CF manifest of the prompter app with the given name
+func (o InitOptions) Manifest(name string) ([]byte, error) {
+	type docker struct {
+		Image    string `yaml:"image"`
+		Username string `yaml:"username,omitempty"`
+	}
+	type application struct {
+		Name            string            `yaml:"name"`
+		Memory          string            `yaml:"memory"`
+		DiskQuota       string            `yaml:"disk_quota"`
+		Instances       int               `yaml:"instances"`
+		NoRoute         bool              `yaml:"no-route"`
+		HealthCheckType string            `yaml:"health-check-type"`
+		Buildpacks      []string          `yaml:"buildpacks,omitempty"`
+		Stack           string            `yaml:"stack,omitempty"`
+		Docker          *docker           `yaml:"docker,omitempty"`
+		Env             map[string]string `yaml:"env,omitempty"`
+		Services        []string          `yaml:"services,omitempty"`
+	}
+
+	app := application{
+		Name:            name,
+		Memory:          withDefault(o.Memory, DefaultMemory),
+		DiskQuota:       withDefault(o.Disk, DefaultDisk),
+		Instances:       1,
+		NoRoute:         true,
+		HealthCheckType: "process",
+		Stack:           o.Stack,
+		Env:             o.Env,
+		Services:        o.Services,
+	}
+	if o.DockerImage != "" {
+		app.Docker = &docker{Image: o.DockerImage, Username: o.DockerUsername}
+	} else {
+		app.Buildpacks = []string{withDefault(o.Buildpack, DefaultBuildpack)}
+	}
+
+	content, err := yaml.Marshal(map[string][]application{"applications": {app}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return append([]byte("---\n"), content...), nil
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,189 @@
+package prompter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/cfclient"
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/runstate"
+)
+
+// Version returns the version of the prompter the options deploy: a hash of the
+// embedded prompter binary, or of the image reference for a Docker based prompter
+func (o InitOptions) Version() string {
+	content := PrompterBinary
+	if o.DockerImage != "" {
+		content = []byte(o.DockerImage)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// writePrompterSource writes the embedded prompter binary and its Procfile to dir, which
+// is pushed as the source of the prompter app
+func writePrompterSource(dir string) error {
+	if len(PrompterBinary) == 0 {
+		return fmt.Errorf("prompter binary not embedded - ensure 'make build-prompter' was run before building the plugin")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create app directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "prompter"), PrompterBinary, 0755); err != nil {
+		return fmt.Errorf("failed to write prompter binary: %w", err)
+	}
+
+	procfile := "web: ./prompter\n"
+	if err := os.WriteFile(filepath.Join(dir, "Procfile"), []byte(procfile), 0644); err != nil {
+		return fmt.Errorf("failed to write Procfile: %w", err)
+	}
+	return nil
+}
+
+// UpgradePrompterApp stages the prompter of this plugin version in the existing prompter
+// app, if the app runs a different version or a Docker image. Only a new package and droplet are created,
+// so env, service bindings and scaling of the app are kept. It returns whether the
+// prompter was upgraded.
+func (d *PrompterInitDeployer) UpgradePrompterApp(apiEndpoint, token string, opts InitOptions) (bool, error) {
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = token[7:]
+	}
+
+	client, err := cfclient.New(apiEndpoint, token)
+	if err != nil {
+		return false, fmt.Errorf("failed to create CF client: %w", err)
+	}
+	d.cfClient = client
+
+	currentSpace, err := d.cliConnection.GetCurrentSpace()
+	if err != nil {
+		return false, fmt.Errorf("failed to get current space: %w", err)
+	}
+
+	prompterGUID, err := client.GetAppGUID(d.prompterName, currentSpace.Guid)
+	if err != nil {
+		return false, fmt.Errorf("prompter app '%s' does not exist, run 'cf prompt-init' without --upgrade to create it", d.prompterName)
+	}
+
+	app, err := client.GetApp(prompterGUID)
+	if err != nil {
+		return false, err
+	}
+	docker := app.Lifecycle.Type == "docker"
+	if docker && opts.DockerImage == "" {
+		return false, fmt.Errorf("prompter app '%s' runs a Docker image, pass the new image with --docker-image", d.prompterName)
+	}
+	if !docker && opts.DockerImage != "" {
+		return false, fmt.Errorf("prompter app '%s' is staged with a buildpack, run 'cf prompt-init' without --upgrade to switch it to a Docker image", d.prompterName)
+	}
+
+	version := opts.Version()
+	current, _, err := client.GetAppLabel(prompterGUID, cfclient.LabelPrompterVersion)
+	if err != nil {
+		return false, fmt.Errorf("failed to read prompter version: %w", err)
+	}
+	// A new image may have been pushed under the same tag, so a Docker based prompter
+	// is always staged again
+	if current == version && !docker {
+		fmt.Printf("Prompter app '%s' is up to date (version %s)\n", d.prompterName, version)
+		return false, nil
+	}
+
+	// Replacing the droplet under a running prompter would restart it with the new one
+	annotations, err := client.GetAppAnnotations(prompterGUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to read prompter lock: %w", err)
+	}
+	if lock, blocking := runstate.Blocking(annotations, time.Now()); blocking {
+		return false, fmt.Errorf("run %s of %s is using the prompter, upgrade it once the run has ended", lock.RunID, lock.Owner)
+	}
+
+	if current == "" {
+		current = "unknown"
+	}
+	if current == version {
+		fmt.Printf("Staging image %s in prompter app '%s' again...\n", opts.DockerImage, d.prompterName)
+	} else {
+		fmt.Printf("Upgrading prompter app '%s' from version %s to %s...\n", d.prompterName, current, version)
+	}
+
+	var packageGUID string
+	if docker {
+		pkg, err := client.CreateDockerPackage(prompterGUID, opts.DockerImage, opts.DockerUsername, os.Getenv("CF_DOCKER_PASSWORD"))
+		if err != nil {
+			return false, err
+		}
+		packageGUID = pkg.GUID
+	} else {
+		tempDir, err := os.MkdirTemp("", "cf-prompter-upgrade-*")
+		if err != nil {
+			return false, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := writePrompterSource(tempDir); err != nil {
+			return false, err
+		}
+		pkg, err := client.CreatePackage(prompterGUID, tempDir)
+		if err != nil {
+			return false, err
+		}
+		packageGUID = pkg.GUID
+	}
+
+	if err := waitForPackageReady(client, packageGUID); err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Staging package %s...\n", cfclient.ShortHash(packageGUID))
+	buildGUID, err := client.TriggerBuild(packageGUID)
+	if err != nil {
+		return false, err
+	}
+	status, err := client.WaitForBuildCompletion(buildGUID, os.Stdout)
+	if err != nil {
+		return false, fmt.Errorf("failed to stage prompter: %w", err)
+	}
+	if status != "STAGED" {
+		return false, fmt.Errorf("staging the prompter failed with status %s", status)
+	}
+
+	dropletGUID, err := client.GetBuildDropletGUID(buildGUID)
+	if err != nil {
+		return false, err
+	}
+	if err := client.SetCurrentDroplet(prompterGUID, dropletGUID); err != nil {
+		return false, err
+	}
+
+	if err := client.UpdateAppLabels(prompterGUID, map[string]string{cfclient.LabelPrompterVersion: version}); err != nil {
+		return false, fmt.Errorf("failed to record prompter version: %w", err)
+	}
+	return true, nil
+}
+
+// waitForPackageReady waits until an uploaded package has been processed and can be staged
+func waitForPackageReady(client *cfclient.Client, packageGUID string) error {
+	deadline := time.Now().Add(5 * time.Minute)
+	for time.Now().Before(deadline) {
+		pkg, err := client.GetPackage(packageGUID)
+		if err != nil {
+			return err
+		}
+		switch pkg.State {
+		case resource.PackageStateReady:
+			return nil
+		case resource.PackageStateFailed, resource.PackageStateExpired:
+			return fmt.Errorf("package %s is %s", cfclient.ShortHash(packageGUID), strings.ToLower(string(pkg.State)))
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("timeout waiting for package %s to be processed", cfclient.ShortHash(packageGUID))
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -119,16 +120,16 @@ func (c *Client) extractImage(img v1.Image, destDir string) error {
 	return nil
 }
 
-func (c *Client) UploadPackage(client *cfclient.Client, appGUID string, sourceDir string, prompt string) error {
+func (c *Client) UploadPackage(client *cfclient.Client, appGUID string, sourceDir string, annotations map[string]string) (*resource.Package, error) {
 	fmt.Printf("Creating package from directory: %s\n", sourceDir)
 
-	pkg, err := client.CreatePackageWithPrompt(appGUID, sourceDir, prompt)
+	pkg, err := client.CreatePackageWithAnnotations(appGUID, sourceDir, annotations)
 	if err != nil {
-		return fmt.Errorf("failed to create package: %w", err)
+		return nil, fmt.Errorf("failed to create package: %w", err)
 	}
 
-	fmt.Printf("Package created successfully: %s\n", pkg.GUID)
+	fmt.Printf("Package created successfully: %s (hash: %s)\n", pkg.GUID, cfclient.ShortHash(pkg.GUID))
 	fmt.Println("Use 'cf prompt-push <app-name> <package-hash>' to deploy this package")
 
-	return nil
+	return pkg, nil
 }
@@ -0,0 +1,92 @@
+package runstate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueuePrefix is the prefix of the annotation keys holding queued prompts, followed by the entry ID
+const QueuePrefix = "queue-"
+
+// maxQueueValueSize is the size limit of an annotation value on CF
+const maxQueueValueSize = 5000
+
+// QueueEntry is a prompt waiting for the prompter to finish the runs before it. Its ID
+// becomes the run ID once the prompter picks it up.
+type QueueEntry struct {
+	ID string `json:"-"`
+	// AppGUID is the app the prompt works on, the prompter's previous app when empty
+	AppGUID          string `json:"app_guid,omitempty"`
+	Prompt           string `json:"prompt"`
+	Validate         string `json:"validate,omitempty"`
+	ValidateAttempts int    `json:"validate_attempts,omitempty"`
+	Agent            string `json:"agent,omitempty"`
+	AgentCommand     string `json:"agent_command,omitempty"`
+	Model            string `json:"model,omitempty"`
+	Provider         string `json:"provider,omitempty"`
+	Template         string `json:"template,omitempty"`
+	// TemplateVars holds the variables of the template as a JSON object
+	TemplateVars string    `json:"template_vars,omitempty"`
+	SubmittedBy  string    `json:"submitted_by,omitempty"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+	// CredentialsService is the service instance holding the credentials of the user
+	// who queued the prompt, the run uses them instead of those of the run before
+	CredentialsService string `json:"credentials_service,omitempty"`
+}
+
+// NewRunID returns a random ID for a run or queue entry
+func NewRunID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Key returns the annotation key of the entry
+func (e QueueEntry) Key() string {
+	return QueuePrefix + e.ID
+}
+
+// Annotations returns the entry as an app annotation
+func (e QueueEntry) Annotations() (map[string]string, error) {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode queue entry: %w", err)
+	}
+	if len(content) > maxQueueValueSize {
+		return nil, fmt.Errorf("prompt is too long to be queued (%d bytes, at most %d)", len(content), maxQueueValueSize)
+	}
+	return map[string]string{e.Key(): string(content)}, nil
+}
+
+// Queue returns the queued prompts recorded in app annotations, oldest first. Entries
+// that cannot be decoded are skipped.
+func Queue(annotations map[string]string) []QueueEntry {
+	var queue []QueueEntry
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, QueuePrefix) || value == "" {
+			continue
+		}
+
+		var entry QueueEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			continue
+		}
+		entry.ID = strings.TrimPrefix(key, QueuePrefix)
+		queue = append(queue, entry)
+	}
+
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].SubmittedAt.Equal(queue[j].SubmittedAt) {
+			return queue[i].SubmittedAt.Before(queue[j].SubmittedAt)
+		}
+		return queue[i].ID < queue[j].ID
+	})
+	return queue
+}
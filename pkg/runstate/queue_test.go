@@ -0,0 +1,71 @@
+package runstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+)
+
+func TestPromptQueue(t *testing.T) {
+	submitted := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+	annotations := map[string]string{
+		KeyRunID:       "3f9a2c1b7d4e",
+		"queue-broken": "not json",
+	}
+	for i, prompt := range []string{"bump timeout", "rename endpoint", "add logging"} {
+		entry := QueueEntry{
+			ID:          []string{"c3", "a1", "b2"}[i],
+			Prompt:      prompt,
+			SubmittedAt: submitted.Add([]time.Duration{2, 0, 1}[i] * time.Minute),
+		}
+		entryAnnotations, err := entry.Annotations()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for key, value := range entryAnnotations {
+			annotations[key] = value
+		}
+	}
+
+	queue := Queue(annotations)
+	if len(queue) != 3 {
+		t.Fatalf("Expected 3 queued prompts, got %d", len(queue))
+	}
+	for i, expected := range []string{"rename endpoint", "add logging", "bump timeout"} {
+		if queue[i].Prompt != expected {
+			t.Errorf("Expected prompt %d to be %q, got %q", i, expected, queue[i].Prompt)
+		}
+	}
+	if queue[0].ID != "a1" || queue[0].Key() != "queue-a1" {
+		t.Errorf("Unexpected first entry: %+v", queue[0])
+	}
+
+	long := QueueEntry{ID: "d4", Prompt: string(make([]byte, 6000))}
+	if _, err := long.Annotations(); err == nil {
+		t.Error("Expected an error for a prompt that does not fit into an annotation")
+	}
+}
+
+func TestLockHandoverToQueue(t *testing.T) {
+	lock := NewLock("developer@example.com", "3f9a2c1b7d4e")
+	state := New(lock.RunID, "add logging")
+	state.Apply(events.Event{Type: events.TypeCompleted})
+
+	annotations := lock.Annotations()
+	for key, value := range state.Annotations() {
+		annotations[key] = value
+	}
+	if _, blocking := Blocking(annotations, time.Now()); blocking {
+		t.Error("Expected the lock of a finished run without queued prompts not to block")
+	}
+
+	entry := QueueEntry{ID: "a1", Prompt: "rename endpoint", SubmittedAt: time.Now()}
+	entryAnnotations, _ := entry.Annotations()
+	for key, value := range entryAnnotations {
+		annotations[key] = value
+	}
+	if _, blocking := Blocking(annotations, time.Now()); !blocking {
+		t.Error("Expected the lock to block while the prompter continues with queued prompts")
+	}
+}
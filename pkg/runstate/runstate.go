@@ -0,0 +1,244 @@
+// Package runstate persists the state of the latest prompter run in annotations on the
+// prompter app, so that 'cf prompt-status' and 'cf prompt-attach' work after 'cf prompt'
+// has exited. It is shared by the plugin and the prompter binary.
+package runstate
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+)
+
+// Annotation keys on the prompter app, without the plugin prefix
+const (
+	KeyRunID   = "run-id"
+	KeyStatus  = "run-status"
+	KeyPhase   = "run-phase"
+	KeyStarted = "run-started"
+	KeyUpdated = "run-updated"
+	KeyPackage = "run-package"
+	KeyError   = "run-error"
+	KeyPrompt  = "run-prompt"
+	// KeyApp holds the GUID of the app the run works on, which a shared prompter needs
+	KeyApp = "run-app"
+	// KeyCancel holds the ID of a run that should be cancelled, the prompter polls it
+	KeyCancel    = "run-cancel"
+	maxValueSize = 1000
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// PhaseStarting is the phase of a run until the prompter reports its first phase
+const PhaseStarting = "starting"
+
+// State is the state of a prompter run
+type State struct {
+	RunID       string
+	Status      string
+	Phase       string
+	Prompt      string
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	PackageGUID string
+	Error       string
+	// AppGUID is the app the run works on, empty for runs recorded by older prompters
+	AppGUID string
+}
+
+// New returns the state of a run that is about to start
+func New(runID, prompt string) State {
+	now := time.Now().UTC()
+	return State{
+		RunID:     runID,
+		Status:    StatusRunning,
+		Phase:     PhaseStarting,
+		Prompt:    prompt,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Apply updates the state with an event reported by the prompter and returns whether
+// the change is worth persisting
+func (s *State) Apply(event events.Event) bool {
+	switch event.Type {
+	case events.TypePhaseStarted:
+		s.Phase = event.Phase
+	case events.TypePackageCreated:
+		s.PackageGUID = event.PackageGUID
+	case events.TypeError:
+		s.Status = StatusFailed
+		s.Error = event.Message
+	case events.TypeCancelled:
+		s.Status = StatusCancelled
+	case events.TypeCompleted:
+		s.Status = StatusSucceeded
+	default:
+		return false
+	}
+
+	s.UpdatedAt = event.Time
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = time.Now().UTC()
+	}
+	return true
+}
+
+// Annotations returns the state as app annotations. Empty fields map to empty values,
+// which remove the annotations left behind by an earlier run.
+func (s State) Annotations() map[string]string {
+	return map[string]string{
+		KeyRunID:   s.RunID,
+		KeyStatus:  s.Status,
+		KeyPhase:   s.Phase,
+		KeyStarted: formatTime(s.StartedAt),
+		KeyUpdated: formatTime(s.UpdatedAt),
+		KeyPackage: s.PackageGUID,
+		KeyError:   truncate(s.Error),
+		KeyPrompt:  truncate(s.Prompt),
+		KeyApp:     s.AppGUID,
+	}
+}
+
+// FromAnnotations reads the state from app annotations, ok is false if no run was recorded
+func FromAnnotations(annotations map[string]string) (state State, ok bool) {
+	if annotations[KeyRunID] == "" {
+		return State{}, false
+	}
+
+	return State{
+		RunID:       annotations[KeyRunID],
+		Status:      annotations[KeyStatus],
+		Phase:       annotations[KeyPhase],
+		Prompt:      annotations[KeyPrompt],
+		StartedAt:   parseTime(annotations[KeyStarted]),
+		UpdatedAt:   parseTime(annotations[KeyUpdated]),
+		PackageGUID: annotations[KeyPackage],
+		Error:       annotations[KeyError],
+		AppGUID:     annotations[KeyApp],
+	}, true
+}
+
+// Finished returns whether the run has ended
+func (s State) Finished() bool {
+	return s.Status == StatusSucceeded || s.Status == StatusFailed || s.Status == StatusCancelled
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// truncate shortens the value to maxValueSize bytes, cutting on a rune boundary so the
+// annotation stays valid UTF-8
+func truncate(value string) string {
+	if len(value) <= maxValueSize {
+		return value
+	}
+
+	end := maxValueSize - 3
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end] + "..."
+}
+
+// Lock annotation keys on the prompter app, without the plugin prefix
+const (
+	KeyLockOwner   = "lock-owner"
+	KeyLockRunID   = "lock-run-id"
+	KeyLockExpires = "lock-expires"
+)
+
+// LeaseDuration is how long a lock is held unless it is renewed or released. The
+// prompter renews it while the run goes on, so it only expires if the prompter is gone.
+const LeaseDuration = time.Hour
+
+// Lock is a lease on a prompter app held by a single run
+type Lock struct {
+	Owner     string
+	RunID     string
+	ExpiresAt time.Time
+}
+
+// NewLock returns a lock for the run that expires after LeaseDuration
+func NewLock(owner, runID string) Lock {
+	return Lock{
+		Owner:     owner,
+		RunID:     runID,
+		ExpiresAt: time.Now().UTC().Add(LeaseDuration),
+	}
+}
+
+// Annotations returns the lock as app annotations
+func (l Lock) Annotations() map[string]string {
+	return map[string]string{
+		KeyLockOwner:   l.Owner,
+		KeyLockRunID:   l.RunID,
+		KeyLockExpires: formatTime(l.ExpiresAt),
+	}
+}
+
+// Active returns whether the lease has not expired yet
+func (l Lock) Active(now time.Time) bool {
+	return now.Before(l.ExpiresAt)
+}
+
+// Renewable returns whether the lease is due to be renewed, once half of it has passed
+func (l Lock) Renewable(now time.Time) bool {
+	return l.ExpiresAt.Sub(now) < LeaseDuration/2
+}
+
+// ReleaseAnnotations returns the annotation updates that release a lock
+func ReleaseAnnotations() map[string]string {
+	return map[string]string{
+		KeyLockOwner:   "",
+		KeyLockRunID:   "",
+		KeyLockExpires: "",
+	}
+}
+
+// LockFromAnnotations reads the lock from app annotations, ok is false if no lock is recorded
+func LockFromAnnotations(annotations map[string]string) (lock Lock, ok bool) {
+	if annotations[KeyLockRunID] == "" {
+		return Lock{}, false
+	}
+
+	return Lock{
+		Owner:     annotations[KeyLockOwner],
+		RunID:     annotations[KeyLockRunID],
+		ExpiresAt: parseTime(annotations[KeyLockExpires]),
+	}, true
+}
+
+// Blocking returns whether the lock prevents a new run. Expired locks and locks of
+// runs that finished but could not release them do not block, unless the run succeeded
+// and the prompter keeps the lock to continue with a queued prompt.
+func Blocking(annotations map[string]string, now time.Time) (lock Lock, blocking bool) {
+	lock, ok := LockFromAnnotations(annotations)
+	if !ok || !lock.Active(now) {
+		return lock, false
+	}
+
+	if state, ok := FromAnnotations(annotations); ok && state.RunID == lock.RunID && state.Finished() {
+		return lock, state.Status == StatusSucceeded && len(Queue(annotations)) > 0
+	}
+
+	return lock, true
+}
@@ -0,0 +1,101 @@
+package runstate
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ruben/cf-prompt-cli-plugin/pkg/events"
+)
+
+func TestRunStateAnnotations(t *testing.T) {
+	state := New("3f9a2c1b7d4e", "add logging")
+	state.Apply(events.Event{Type: events.TypePhaseStarted, Phase: events.PhaseAgent})
+
+	restored, ok := FromAnnotations(state.Annotations())
+	if !ok {
+		t.Fatal("Expected state to be restored from annotations")
+	}
+	if restored.RunID != state.RunID || restored.Phase != events.PhaseAgent || restored.Status != StatusRunning {
+		t.Errorf("Unexpected restored state: %+v", restored)
+	}
+	if !restored.StartedAt.Equal(state.StartedAt.Truncate(time.Second)) {
+		t.Errorf("Expected start time %v, got %v", state.StartedAt, restored.StartedAt)
+	}
+
+	if annotations := state.Annotations(); annotations[KeyPackage] != "" || annotations[KeyError] != "" {
+		t.Error("Fields of earlier runs should be cleared")
+	}
+
+	state.Apply(events.Event{Type: events.TypeCancelled})
+	if state.Status != StatusCancelled || !state.Finished() {
+		t.Errorf("Expected a finished, cancelled run, got %+v", state)
+	}
+
+	if _, ok := FromAnnotations(map[string]string{}); ok {
+		t.Error("Expected no state without a run ID")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "Short value", value: "validation failed"},
+		{name: "ASCII", value: strings.Repeat("a", 2*maxValueSize)},
+		{name: "Multi-byte characters", value: strings.Repeat("ä", maxValueSize)},
+		{name: "Multi-byte character at the boundary", value: strings.Repeat("a", maxValueSize-4) + strings.Repeat("€", 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			truncated := truncate(tt.value)
+
+			if len(truncated) > maxValueSize {
+				t.Errorf("Expected at most %d bytes, got %d", maxValueSize, len(truncated))
+			}
+			if !utf8.ValidString(truncated) {
+				t.Errorf("Expected valid UTF-8, got %q", truncated[len(truncated)-10:])
+			}
+			if len(tt.value) <= maxValueSize && truncated != tt.value {
+				t.Errorf("Expected a short value to be kept, got %q", truncated)
+			}
+		})
+	}
+}
+
+func TestPrompterLock(t *testing.T) {
+	now := time.Now()
+	lock := NewLock("developer@example.com", "3f9a2c1b7d4e")
+	annotations := lock.Annotations()
+
+	restored, ok := LockFromAnnotations(annotations)
+	if !ok || restored.Owner != lock.Owner || restored.RunID != lock.RunID {
+		t.Fatalf("Unexpected restored lock: %+v", restored)
+	}
+	if _, blocking := Blocking(annotations, now); !blocking {
+		t.Error("Expected an active lock to block")
+	}
+	if _, blocking := Blocking(annotations, now.Add(2*LeaseDuration)); blocking {
+		t.Error("Expected an expired lock not to block")
+	}
+
+	// A finished run that could not release its lock does not block
+	state := New(lock.RunID, "add logging")
+	state.Apply(events.Event{Type: events.TypeCompleted})
+	for key, value := range state.Annotations() {
+		annotations[key] = value
+	}
+	if _, blocking := Blocking(annotations, now); blocking {
+		t.Error("Expected the lock of a finished run not to block")
+	}
+
+	for key, value := range ReleaseAnnotations() {
+		annotations[key] = value
+	}
+	if _, ok := LockFromAnnotations(annotations); ok {
+		t.Error("Expected no lock after release")
+	}
+}
@@ -0,0 +1,195 @@
+// Package templates stores named prompt templates in the CF CLI config directory and
+// renders them with variables written as {{name}}.
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Template is a named prompt with {{variable}} placeholders
+type Template struct {
+	Name      string    `json:"-"`
+	Prompt    string    `json:"prompt"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	namePattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+)
+
+// ErrNotFound is returned for a template that does not exist
+var ErrNotFound = errors.New("template not found")
+
+// Store is the template file of the current user
+type Store struct {
+	path string
+}
+
+// DefaultPath returns the template file in the CF CLI config directory, which is
+// $CF_HOME/.cf or ~/.cf
+func DefaultPath() (string, error) {
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		var err error
+		home, err = os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+	}
+	return filepath.Join(home, ".cf", "prompt-templates.json"), nil
+}
+
+// NewStore returns the store kept in the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) load() (map[string]Template, error) {
+	templates := make(map[string]Template)
+
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	if err := json.Unmarshal(content, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	for name, template := range templates {
+		template.Name = name
+		templates[name] = template
+	}
+	return templates, nil
+}
+
+func (s *Store) write(templates map[string]Template) error {
+	content, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode templates: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write templates: %w", err)
+	}
+	return nil
+}
+
+// List returns all templates ordered by name
+func (s *Store) List() ([]Template, error) {
+	templates, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Template, 0, len(templates))
+	for _, template := range templates {
+		list = append(list, template)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns the template with the name
+func (s *Store) Get(name string) (Template, error) {
+	templates, err := s.load()
+	if err != nil {
+		return Template{}, err
+	}
+
+	template, ok := templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return template, nil
+}
+
+// Save creates or replaces the template with the name
+func (s *Store) Save(name, prompt string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name '%s', use letters, digits, '.', '_' and '-'", name)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return fmt.Errorf("the template is empty")
+	}
+
+	templates, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	templates[name] = Template{Name: name, Prompt: prompt, UpdatedAt: time.Now().UTC()}
+	return s.write(templates)
+}
+
+// Delete removes the template with the name
+func (s *Store) Delete(name string) error {
+	templates, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := templates[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(templates, name)
+	return s.write(templates)
+}
+
+// Variables returns the names of the variables used in the template, in order of first use
+func (t Template) Variables() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range variablePattern.FindAllStringSubmatch(t.Prompt, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render replaces the variables of the template with their values. Every variable of
+// the template must be given, and every given value must be used by the template.
+func (t Template) Render(vars map[string]string) (string, error) {
+	var missing []string
+	used := make(map[string]bool)
+	for _, name := range t.Variables() {
+		used[name] = true
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing value for %s, set it with --var NAME=VALUE", strings.Join(missing, ", "))
+	}
+
+	var unknown []string
+	for name := range vars {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("template '%s' has no variable %s", t.Name, strings.Join(unknown, ", "))
+	}
+
+	return variablePattern.ReplaceAllStringFunc(t.Prompt, func(placeholder string) string {
+		return vars[variablePattern.FindStringSubmatch(placeholder)[1]]
+	}), nil
+}
@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), ".cf", "prompt-json"))
+
+	if list, err := store.List(); err != nil || len(list) != 0 {
+		t.Fatalf("Expected an empty store, got %v, %v", list, err)
+	}
+
+	if err := store.Save("upgrade", "upgrade dependency {{name}} to {{ version }}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Save("health", "add a /health endpoint on path {{path}}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Save("bad name", "text"); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 2 || list[0].Name != "health" || list[1].Name != "upgrade" {
+		t.Fatalf("Unexpected templates: %v, %v", list, err)
+	}
+
+	template, err := store.Get("upgrade")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if variables := template.Variables(); len(variables) != 2 || variables[0] != "name" || variables[1] != "version" {
+		t.Errorf("Unexpected variables: %v", variables)
+	}
+
+	prompt, err := template.Render(map[string]string{"name": "gin", "version": "1.10.0"})
+	if err != nil || prompt != "upgrade dependency gin to 1.10.0" {
+		t.Errorf("Unexpected rendered prompt: %q, %v", prompt, err)
+	}
+	if _, err := template.Render(map[string]string{"name": "gin"}); err == nil {
+		t.Error("Expected an error for a missing variable")
+	}
+	if _, err := template.Render(map[string]string{"name": "gin", "version": "1.10.0", "verison": "1.9"}); err == nil {
+		t.Error("Expected an error for an unknown variable")
+	}
+
+	if err := store.Delete("upgrade"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Get("upgrade"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	if err := store.Delete("upgrade"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}